@@ -4,10 +4,21 @@ import (
 	"context"
 	"distributed-configuration/internal/agent/client"
 	"distributed-configuration/internal/agent/config"
+	"distributed-configuration/internal/agent/handler"
+	"distributed-configuration/internal/agent/hook"
 	"distributed-configuration/internal/agent/repository"
 	"distributed-configuration/internal/agent/service"
+	"distributed-configuration/internal/agent/sink"
 	"distributed-configuration/pkg/utils"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
+	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
@@ -23,12 +34,86 @@ func main() {
 		return
 	}
 
-	repo := repository.NewFileStore(&log, cfg.FilePath)
+	repo, err := repository.NewFileStore(&log, cfg.FilePath)
+	if err != nil {
+		log.Fatal(err.Error())
+		return
+	}
+	defer repo.Close()
+
 	controller := client.NewControllerClient(&log, cfg)
 	worker := client.NewWorkerClient(&log, cfg)
 
-	service := service.NewAgentService(controller, worker, repo, &log, cfg)
+	sinks, err := sink.New(&log, cfg, worker)
+	if err != nil {
+		log.Fatal(err.Error())
+		return
+	}
+
+	hooks, err := hook.New(&log, cfg)
+	if err != nil {
+		log.Fatal(err.Error())
+		return
+	}
+
+	service := service.NewAgentService(controller, sinks, hooks, repo, &log, cfg)
+
+	var server *http.Server
+	if cfg.APIAddr != "" {
+		handler := handler.NewHandler(&log, cfg, service)
+
+		mux := http.NewServeMux()
+		mux.Handle("/status", handler.Authentication(http.HandlerFunc(handler.Status)))
+		mux.Handle("/config", handler.Authentication(http.HandlerFunc(handler.Config)))
+		mux.Handle("/resync", handler.Authentication(http.HandlerFunc(handler.Resync)))
+		mux.Handle("/register", handler.Authentication(http.HandlerFunc(handler.Register)))
+		mux.Handle("/push", handler.Authentication(http.HandlerFunc(handler.Push)))
+
+		listener, err := listen(cfg.APIAddr)
+		if err != nil {
+			log.Fatal(err.Error())
+			return
+		}
+
+		server = &http.Server{Handler: mux}
+		go func() {
+			log.Info("agent api started", zap.String("addr", cfg.APIAddr))
+			if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+				log.Fatal(err.Error())
+			}
+		}()
+	}
+
+	// SIGINT or SIGTERM cancel ctx, Start then drains in-flight deliveries
+	// and flushes the state before returning.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	ctx := context.Background()
 	service.Start(ctx)
+
+	if server != nil {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Error(err.Error())
+		}
+	}
+	log.Sync()
+}
+
+// listen binds the local api to a tcp address such as 127.0.0.1:8282 or to
+// a unix socket given as unix:///run/agent/api.sock.
+func listen(addr string) (net.Listener, error) {
+	path, ok := strings.CutPrefix(addr, "unix://")
+	if !ok {
+		return net.Listen("tcp", addr)
+	}
+
+	// a socket left behind by a previous run would fail the bind.
+	err := os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return net.Listen("unix", path)
 }
@@ -0,0 +1,429 @@
+package main
+
+import (
+	"context"
+	"distributed-configuration/pkg/utils"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// get handles `configctl get [-version N]`.
+func (a *app) get(ctx context.Context, args []string) error {
+	fs := a.flags("get")
+	version := fs.Int("version", 0, "configuration version, latest by default")
+	_, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+
+	config, err := a.client.Get(ctx, *version)
+	if err != nil {
+		return err
+	}
+
+	return a.printDocument(json.RawMessage(config.Data))
+}
+
+// set handles `configctl set -f file.json|yaml|toml [-dry-run]`.
+func (a *app) set(ctx context.Context, args []string) error {
+	fs := a.flags("set")
+	file := fs.String("f", "", "configuration file, - for stdin")
+	dryRun := fs.Bool("dry-run", false, "only show the diff that would be applied")
+	_, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if *file == "" {
+		return errors.New("usage: configctl set -f file.json [-dry-run]")
+	}
+
+	data, err := readDocument(*file)
+	if err != nil {
+		return err
+	}
+
+	return a.apply(ctx, data, anyVersion, *dryRun)
+}
+
+// patch handles `configctl patch -f patch.json|<json> [-dry-run]`, the patch
+// is a JSON merge patch (RFC 7386) on the latest configuration.
+func (a *app) patch(ctx context.Context, args []string) error {
+	fs := a.flags("patch")
+	file := fs.String("f", "", "merge patch file, - for stdin")
+	dryRun := fs.Bool("dry-run", false, "only show the diff that would be applied")
+	rest, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+
+	var patchData []byte
+	switch {
+	case *file != "" && len(rest) == 0:
+		patchData, err = readConfigFile(*file)
+	case *file == "" && len(rest) == 1:
+		patchData, err = utils.Decode(utils.FormatJSON, []byte(rest[0]))
+		if err != nil {
+			return fmt.Errorf("invalid patch: %w", err)
+		}
+	default:
+		return errors.New("usage: configctl patch -f patch.json|<json> [-dry-run]")
+	}
+	if err != nil {
+		return err
+	}
+
+	patch, err := utils.DecodeJSON(patchData)
+	if err != nil {
+		return fmt.Errorf("invalid patch: %w", err)
+	}
+
+	latest, version, err := a.latest(ctx)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(mergePatch(latest, patch))
+	if err != nil {
+		return err
+	}
+
+	err = checkDocument(data)
+	if err != nil {
+		return err
+	}
+
+	return a.apply(ctx, data, version, *dryRun)
+}
+
+// diff handles `configctl diff [-from N] [-to N] [-f file]`. Without a file
+// it compares version to with from, which default to the latest and the one
+// before it, with a file it compares from, the latest by default, with it.
+func (a *app) diff(ctx context.Context, args []string) error {
+	fs := a.flags("diff")
+	from := fs.Int("from", 0, "old version")
+	to := fs.Int("to", 0, "new version, latest by default")
+	file := fs.String("f", "", "compare with this configuration file instead of a version")
+	_, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+
+	var oldData, newData json.RawMessage
+	if *file != "" {
+		newData, err = readDocument(*file)
+		if err != nil {
+			return err
+		}
+
+		old, err := a.client.Get(ctx, *from)
+		if err != nil && !errors.Is(err, utils.ErrNotFound) {
+			return err
+		}
+		oldData = json.RawMessage(old.Data)
+	} else {
+		newer, err := a.client.Get(ctx, *to)
+		if err != nil {
+			return err
+		}
+		newData = json.RawMessage(newer.Data)
+
+		if *from == 0 {
+			*from = newer.Version - 1
+		}
+		if *from > 0 {
+			old, err := a.client.Get(ctx, *from)
+			if err != nil {
+				return err
+			}
+			oldData = json.RawMessage(old.Data)
+		}
+	}
+
+	changes, err := diffDocuments(oldData, newData)
+	if err != nil {
+		return err
+	}
+
+	return a.printDiff(changes)
+}
+
+// history handles `configctl history`.
+func (a *app) history(ctx context.Context, args []string) error {
+	_, err := parse(a.flags("history"), args)
+	if err != nil {
+		return err
+	}
+
+	versions, err := a.client.History(ctx)
+	if err != nil {
+		return err
+	}
+
+	return a.print(versions, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "VERSION\tCREATED\tPINNED")
+		for _, v := range versions {
+			fmt.Fprintf(w, "%d\t%s\t%t\n", v.Version, v.CreatedAt.Local().Format(time.DateTime), v.Pinned)
+		}
+	})
+}
+
+// rollback handles `configctl rollback <version> [-dry-run]`.
+func (a *app) rollback(ctx context.Context, args []string) error {
+	fs := a.flags("rollback")
+	dryRun := fs.Bool("dry-run", false, "only show the diff that would be applied")
+	rest, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 1 {
+		return errors.New("usage: configctl rollback <version> [-dry-run]")
+	}
+
+	version, err := strconv.Atoi(rest[0])
+	if err != nil || version < 1 {
+		return fmt.Errorf("invalid version %q", rest[0])
+	}
+
+	if *dryRun {
+		target, err := a.client.Get(ctx, version)
+		if err != nil {
+			return err
+		}
+		return a.applyDryRun(ctx, json.RawMessage(target.Data))
+	}
+
+	saved, err := a.client.Rollback(ctx, version)
+	if errors.Is(err, utils.ErrNotModified) {
+		return a.printResult(0, fmt.Sprintf("version %d is already the latest configuration", version))
+	}
+	if err != nil {
+		return err
+	}
+
+	return a.printResult(saved, fmt.Sprintf("rolled back to version %d as version %d", version, saved))
+}
+
+// agents handles `configctl agents list` and `configctl agents rm <id>...`.
+func (a *app) agents(ctx context.Context, args []string) error {
+	rest, err := parse(a.flags("agents"), args)
+	if err != nil {
+		return err
+	}
+
+	if len(rest) == 0 || rest[0] == "list" {
+		agents, err := a.client.Agents(ctx)
+		if err != nil {
+			return err
+		}
+
+		return a.print(agents, func(w *tabwriter.Writer) {
+			fmt.Fprintln(w, "ID\tNAME\tHOST\tVERSION\tLAST SEEN")
+			for _, agent := range agents {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n",
+					agent.Id, agent.Name, agent.Host, agent.ConfigVersion,
+					agent.LastSeen.Local().Format(time.DateTime))
+			}
+		})
+	}
+
+	if rest[0] != "rm" || len(rest) < 2 {
+		return errors.New("usage: configctl agents list | rm <agent-id>...")
+	}
+
+	for _, agentID := range rest[1:] {
+		err = a.client.RemoveAgent(ctx, agentID)
+		if err != nil {
+			return fmt.Errorf("remove agent %s: %w", agentID, err)
+		}
+		fmt.Fprintf(a.out, "removed agent %s\n", agentID)
+	}
+
+	return nil
+}
+
+// validate handles `configctl validate -f file.json`.
+func (a *app) validate(args []string) error {
+	fs := a.flags("validate")
+	file := fs.String("f", "", "configuration file, - for stdin")
+	_, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if *file == "" {
+		return errors.New("usage: configctl validate -f file.json")
+	}
+
+	_, err = readDocument(*file)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(a.out, "%s is valid\n", *file)
+	return nil
+}
+
+// anyVersion saves over whatever version is the latest.
+const anyVersion = -1
+
+// apply saves data, which was derived from version base unless that is
+// anyVersion, and fails when another version was saved since base was read.
+func (a *app) apply(ctx context.Context, data json.RawMessage, base int, dryRun bool) error {
+	if dryRun {
+		return a.applyDryRun(ctx, data)
+	}
+
+	var version int
+	var err error
+	if base == anyVersion {
+		version, err = a.client.Save(ctx, data)
+	} else {
+		version, err = a.client.SaveIfLatest(ctx, data, base)
+	}
+	if errors.Is(err, utils.ErrNotModified) {
+		return a.printResult(0, "configuration unchanged")
+	}
+	if errors.Is(err, utils.ErrPreconditionFailed) || errors.Is(err, utils.ErrConflict) {
+		return fmt.Errorf("configuration changed since version %d was read, run the command again: %w", base, err)
+	}
+	if err != nil {
+		return err
+	}
+
+	return a.printResult(version, fmt.Sprintf("saved version %d", version))
+}
+
+func (a *app) applyDryRun(ctx context.Context, data json.RawMessage) error {
+	latest, err := a.client.Get(ctx, 0)
+	if err != nil && !errors.Is(err, utils.ErrNotFound) {
+		return err
+	}
+
+	changes, err := diffDocuments(json.RawMessage(latest.Data), data)
+	if err != nil {
+		return err
+	}
+
+	return a.printDiff(changes)
+}
+
+// latest returns the decoded latest configuration and its version, an empty
+// object and 0 when none was saved yet.
+func (a *app) latest(ctx context.Context) (any, int, error) {
+	config, err := a.client.Get(ctx, 0)
+	if errors.Is(err, utils.ErrNotFound) {
+		return map[string]any{}, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	doc, err := utils.DecodeJSON(config.Data)
+	return doc, config.Version, err
+}
+
+// parse parses flags that may appear before, between or after the
+// positional arguments and returns the positional ones.
+func parse(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		err := fs.Parse(args)
+		if err != nil {
+			return nil, err
+		}
+
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+func readFile(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
+
+// readConfigFile reads a JSON, YAML or TOML file, picked by its extension,
+// and returns it as JSON.
+func readConfigFile(path string) (json.RawMessage, error) {
+	data, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	format := utils.FormatJSON
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		format = utils.FormatYAML
+	case ".toml":
+		format = utils.FormatTOML
+	}
+
+	doc, err := utils.Decode(format, data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return doc, nil
+}
+
+// readDocument reads and checks a configuration file.
+func readDocument(path string) (json.RawMessage, error) {
+	data, err := readConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkDocument(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return data, nil
+}
+
+// checkDocument accepts the documents agents can apply, a JSON object with
+// the configuration in its data member.
+func checkDocument(data []byte) error {
+	v, err := utils.DecodeJSON(data)
+	if err != nil {
+		return fmt.Errorf("invalid json: %w", err)
+	}
+
+	doc, ok := v.(map[string]any)
+	if !ok {
+		return errors.New("configuration must be a json object")
+	}
+	if _, ok := doc["data"]; !ok {
+		return errors.New(`configuration has no "data" member`)
+	}
+
+	return nil
+}
+
+// printResult reports a save, version is 0 when nothing changed.
+func (a *app) printResult(version int, msg string) error {
+	res := map[string]any{
+		"changed": version > 0,
+		"message": msg,
+	}
+	if version > 0 {
+		res["version"] = version
+	}
+
+	return a.print(res, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, msg)
+	})
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"distributed-configuration/pkg/utils"
+	"encoding/json"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// change is one difference between two documents, Path is a JSON Pointer.
+type change struct {
+	Op   string `json:"op"`
+	Path string `json:"path"`
+	Old  any    `json:"old,omitempty"`
+	New  any    `json:"new,omitempty"`
+}
+
+// diffDocuments lists the changes from old to new, a missing old document
+// counts as an empty object.
+func diffDocuments(oldData, newData json.RawMessage) ([]change, error) {
+	var old any = map[string]any{}
+	if len(oldData) > 0 {
+		var err error
+		old, err = utils.DecodeJSON(oldData)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	new, err := utils.DecodeJSON(newData)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []change{}
+	diffValues("", old, new, &changes)
+	return changes, nil
+}
+
+func diffValues(path string, old, new any, changes *[]change) {
+	oldMap, oldIsMap := old.(map[string]any)
+	newMap, newIsMap := new.(map[string]any)
+	if oldIsMap && newIsMap {
+		union := maps.Clone(oldMap)
+		maps.Copy(union, newMap)
+		keys := slices.Sorted(maps.Keys(union))
+
+		for _, k := range keys {
+			ov, inOld := oldMap[k]
+			nv, inNew := newMap[k]
+			diffMember(path+"/"+escapeToken(k), ov, inOld, nv, inNew, changes)
+		}
+		return
+	}
+
+	oldArr, oldIsArr := old.([]any)
+	newArr, newIsArr := new.([]any)
+	if oldIsArr && newIsArr {
+		for i := range max(len(oldArr), len(newArr)) {
+			var ov, nv any
+			if i < len(oldArr) {
+				ov = oldArr[i]
+			}
+			if i < len(newArr) {
+				nv = newArr[i]
+			}
+			diffMember(path+"/"+strconv.Itoa(i), ov, i < len(oldArr), nv, i < len(newArr), changes)
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(old, new) {
+		*changes = append(*changes, change{Op: "replace", Path: path, Old: old, New: new})
+	}
+}
+
+func diffMember(path string, old any, inOld bool, new any, inNew bool, changes *[]change) {
+	switch {
+	case !inOld:
+		*changes = append(*changes, change{Op: "add", Path: path, New: new})
+	case !inNew:
+		*changes = append(*changes, change{Op: "remove", Path: path, Old: old})
+	default:
+		diffValues(path, old, new, changes)
+	}
+}
+
+func escapeToken(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
+
+// mergePatch applies a JSON merge patch (RFC 7386) to target.
+func mergePatch(target, patch any) any {
+	patchMap, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	res := map[string]any{}
+	if targetMap, ok := target.(map[string]any); ok {
+		maps.Copy(res, targetMap)
+	}
+
+	for k, v := range patchMap {
+		if v == nil {
+			delete(res, k)
+			continue
+		}
+		res[k] = mergePatch(res[k], v)
+	}
+
+	return res
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"distributed-configuration/pkg/utils"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDiffDocuments(t *testing.T) {
+	tests := []struct {
+		name string
+		old  string
+		new  string
+		want []change
+	}{
+		{"equal", `{"a":1}`, `{"a":1}`, []change{}},
+		{"no old document", ``, `{"a":1}`, []change{{Op: "add", Path: "/a", New: json.Number("1")}}},
+		{"replace", `{"a":1}`, `{"a":2}`, []change{{Op: "replace", Path: "/a", Old: json.Number("1"), New: json.Number("2")}}},
+		{"add and remove sorted", `{"b":1,"c":1}`, `{"a":1,"c":1}`, []change{
+			{Op: "add", Path: "/a", New: json.Number("1")},
+			{Op: "remove", Path: "/b", Old: json.Number("1")},
+		}},
+		{"nested", `{"db":{"host":"a","port":1}}`, `{"db":{"host":"b","port":1}}`, []change{
+			{Op: "replace", Path: "/db/host", Old: "a", New: "b"},
+		}},
+		{"array", `{"l":[1,2]}`, `{"l":[1,3,4]}`, []change{
+			{Op: "replace", Path: "/l/1", Old: json.Number("2"), New: json.Number("3")},
+			{Op: "add", Path: "/l/2", New: json.Number("4")},
+		}},
+		{"array shrinks", `{"l":[1,2]}`, `{"l":[1]}`, []change{{Op: "remove", Path: "/l/1", Old: json.Number("2")}}},
+		{"type change", `{"a":{"b":1}}`, `{"a":[1]}`, []change{
+			{Op: "replace", Path: "/a", Old: map[string]any{"b": json.Number("1")}, New: []any{json.Number("1")}},
+		}},
+		{"to null", `{"a":1}`, `{"a":null}`, []change{{Op: "replace", Path: "/a", Old: json.Number("1")}}},
+		{"escaped keys", `{}`, `{"a/b":1,"c~d":2}`, []change{
+			{Op: "add", Path: "/a~1b", New: json.Number("1")},
+			{Op: "add", Path: "/c~0d", New: json.Number("2")},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := diffDocuments(json.RawMessage(tt.old), json.RawMessage(tt.new))
+			if err != nil {
+				t.Fatalf("diff: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffDocumentsInvalid(t *testing.T) {
+	_, err := diffDocuments(json.RawMessage(`{"a":`), json.RawMessage(`{}`))
+	if err == nil {
+		t.Fatal("diff against an invalid old document succeeded")
+	}
+	_, err = diffDocuments(nil, json.RawMessage(`{"a":`))
+	if err == nil {
+		t.Fatal("diff against an invalid new document succeeded")
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	// the cases from RFC 7386 appendix A.
+	tests := []struct {
+		target string
+		patch  string
+		want   string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.patch, func(t *testing.T) {
+			target, err := utils.DecodeJSON([]byte(tt.target))
+			if err != nil {
+				t.Fatalf("decode target: %v", err)
+			}
+			patch, err := utils.DecodeJSON([]byte(tt.patch))
+			if err != nil {
+				t.Fatalf("decode patch: %v", err)
+			}
+			want, err := utils.DecodeJSON([]byte(tt.want))
+			if err != nil {
+				t.Fatalf("decode want: %v", err)
+			}
+
+			got := mergePatch(target, patch)
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("merge %s into %s gave %v, want %s", tt.patch, tt.target, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+// Command configctl drives the controller admin API for operators.
+package main
+
+import (
+	"context"
+	"distributed-configuration/pkg/adminclient"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+const usage = `usage: configctl [global flags] <command> [flags]
+
+commands (configuration files may be JSON, YAML or TOML, picked by extension):
+  get       [-version N]                   print a configuration version
+  set       -f file.json [-dry-run]        save a new configuration
+  patch     -f patch.json|<json> [-dry-run] merge a JSON merge patch into the latest configuration
+  diff      [-from N] [-to N] [-f file]    compare two versions, or the latest one with a file
+  history                                  list configuration versions
+  rollback  <version> [-dry-run]           save an earlier version as the latest one
+  agents    list | rm <agent-id>           list or remove registered agents
+  validate  -f file.json                   check a configuration file without saving it
+
+global flags:
+  -url       controller url (env CONFIGCTL_URL)
+  -secret    admin secret (env CONFIGCTL_SECRET)
+  -profile   profile from the profile file (env CONFIGCTL_PROFILE), wins over the env
+  -config    profile file (env CONFIGCTL_CONFIG, default ~/.configctl.yaml)
+  -o         output format: table, json, yaml or toml
+`
+
+type app struct {
+	client *adminclient.Client
+	out    io.Writer
+	output string
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	err := run(ctx, os.Args[1:], os.Stdout)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "configctl:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string, out io.Writer) error {
+	var (
+		a       = &app{out: out}
+		opts    profile
+		name    string
+		cfgPath string
+	)
+
+	fs := flag.NewFlagSet("configctl", flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	fs.StringVar(&opts.URL, "url", "", "controller url")
+	fs.StringVar(&opts.Secret, "secret", "", "admin secret")
+	fs.StringVar(&name, "profile", "", "profile name")
+	fs.StringVar(&cfgPath, "config", "", "profile file")
+	fs.StringVar(&a.output, "o", "", "output format: table, json, yaml or toml")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+
+	creds, err := loadProfile(cfgPath, name)
+	if err != nil {
+		return err
+	}
+	explicit := name != "" || os.Getenv("CONFIGCTL_PROFILE") != ""
+	opts = creds.merge(opts, explicit)
+	if a.output == "" {
+		a.output = opts.Output
+	}
+
+	command, args := fs.Arg(0), fs.Args()[1:]
+	if command == "validate" {
+		return a.validate(args)
+	}
+
+	a.client, err = adminclient.New(adminclient.Options{URL: opts.URL, Secret: opts.Secret})
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case "get":
+		return a.get(ctx, args)
+	case "set":
+		return a.set(ctx, args)
+	case "patch":
+		return a.patch(ctx, args)
+	case "diff":
+		return a.diff(ctx, args)
+	case "history":
+		return a.history(ctx, args)
+	case "rollback":
+		return a.rollback(ctx, args)
+	case "agents":
+		return a.agents(ctx, args)
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown command %q", command)
+	}
+}
+
+// flags returns the flag set of a command, it accepts -o as well so the
+// output format can follow the command.
+func (a *app) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.StringVar(&a.output, "o", a.output, "output format: table, json, yaml or toml")
+	return fs
+}
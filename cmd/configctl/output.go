@@ -0,0 +1,74 @@
+package main
+
+import (
+	"distributed-configuration/pkg/utils"
+	"encoding/json"
+	"fmt"
+	"text/tabwriter"
+)
+
+// print writes v as JSON, YAML or TOML, the table format is left to table.
+func (a *app) print(v any, table func(w *tabwriter.Writer)) error {
+	switch a.output {
+	case "table":
+		w := tabwriter.NewWriter(a.out, 0, 4, 2, ' ', 0)
+		table(w)
+		return w.Flush()
+	case utils.FormatJSON, utils.FormatYAML, utils.FormatTOML:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		return a.printDocument(data)
+	default:
+		return fmt.Errorf("unsupported output format %q", a.output)
+	}
+}
+
+// printDocument writes a configuration document, as JSON for the table
+// format.
+func (a *app) printDocument(data json.RawMessage) error {
+	format := a.output
+	if format == "table" {
+		format = utils.FormatJSON
+	}
+	if format != utils.FormatJSON && format != utils.FormatYAML && format != utils.FormatTOML {
+		return fmt.Errorf("unsupported output format %q", a.output)
+	}
+
+	out, err := utils.Encode(format, data)
+	if err != nil {
+		return err
+	}
+
+	_, err = a.out.Write(out)
+	return err
+}
+
+func (a *app) printDiff(changes []change) error {
+	return a.print(changes, func(w *tabwriter.Writer) {
+		if len(changes) == 0 {
+			fmt.Fprintln(w, "no changes")
+			return
+		}
+
+		for _, c := range changes {
+			switch c.Op {
+			case "add":
+				fmt.Fprintf(w, "+ %s\t%s\n", c.Path, compact(c.New))
+			case "remove":
+				fmt.Fprintf(w, "- %s\t%s\n", c.Path, compact(c.Old))
+			default:
+				fmt.Fprintf(w, "~ %s\t%s -> %s\n", c.Path, compact(c.Old), compact(c.New))
+			}
+		}
+	})
+}
+
+func compact(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
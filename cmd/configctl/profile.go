@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// profileFile holds named controller credentials, e.g.
+//
+//	current: prod
+//	profiles:
+//	  prod:
+//	    url: https://controller.example.com
+//	    secret: s3cret
+//	    output: yaml
+type profileFile struct {
+	Current  string             `yaml:"current"`
+	Profiles map[string]profile `yaml:"profiles"`
+}
+
+type profile struct {
+	URL    string `yaml:"url"`
+	Secret string `yaml:"secret"`
+	Output string `yaml:"output"`
+}
+
+// merge returns p overridden by the non-empty fields of flags. The
+// environment sits in between, except for an explicitly chosen profile,
+// which wins over it.
+func (p profile) merge(flags profile, explicit bool) profile {
+	pick := func(values ...string) string {
+		for _, v := range values {
+			if v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+
+	first := profile{URL: os.Getenv("CONFIGCTL_URL"), Secret: os.Getenv("CONFIGCTL_SECRET")}
+	second := p
+	if explicit {
+		first, second = p, first
+	}
+
+	return profile{
+		URL:    pick(flags.URL, first.URL, second.URL, "http://localhost:8080"),
+		Secret: pick(flags.Secret, first.Secret, second.Secret),
+		Output: pick(flags.Output, p.Output, "table"),
+	}
+}
+
+// loadProfile reads the named profile, the current one when name is empty.
+// A missing default profile file is not an error.
+func loadProfile(path, name string) (profile, error) {
+	if name == "" {
+		name = os.Getenv("CONFIGCTL_PROFILE")
+	}
+	if path == "" {
+		path = os.Getenv("CONFIGCTL_CONFIG")
+	}
+
+	explicit := path != ""
+	if !explicit {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return profile{}, nil
+		}
+		path = filepath.Join(home, ".configctl.yaml")
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !explicit && name == "" {
+		return profile{}, nil
+	}
+	if err != nil {
+		return profile{}, fmt.Errorf("read profile file: %w", err)
+	}
+
+	var file profileFile
+	err = yaml.Unmarshal(data, &file)
+	if err != nil {
+		return profile{}, fmt.Errorf("parse profile file %s: %w", path, err)
+	}
+
+	if name == "" {
+		name = file.Current
+	}
+	if name == "" {
+		return file.Profiles["default"], nil
+	}
+
+	p, ok := file.Profiles[name]
+	if !ok {
+		return profile{}, fmt.Errorf("profile %q not found in %s", name, path)
+	}
+
+	return p, nil
+}
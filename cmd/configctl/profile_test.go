@@ -0,0 +1,113 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProfileMerge(t *testing.T) {
+	stored := profile{URL: "http://profile", Secret: "profile-secret", Output: "yaml"}
+
+	tests := []struct {
+		name     string
+		profile  profile
+		flags    profile
+		envURL   string
+		explicit bool
+		want     profile
+	}{
+		{"defaults", profile{}, profile{}, "", false, profile{URL: "http://localhost:8080", Output: "table"}},
+		{"profile", stored, profile{}, "", false, stored},
+		{"env over current profile", stored, profile{}, "http://env", false, profile{URL: "http://env", Secret: "profile-secret", Output: "yaml"}},
+		{"explicit profile over env", stored, profile{}, "http://env", true, stored},
+		{"env fills explicit profile", profile{Secret: "profile-secret"}, profile{}, "http://env", true, profile{URL: "http://env", Secret: "profile-secret", Output: "table"}},
+		{"flags win", stored, profile{URL: "http://flag", Output: "json"}, "http://env", true, profile{URL: "http://flag", Secret: "profile-secret", Output: "json"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CONFIGCTL_URL", tt.envURL)
+			t.Setenv("CONFIGCTL_SECRET", "")
+
+			got := tt.profile.merge(tt.flags, tt.explicit)
+			if got != tt.want {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadProfile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "configctl.yaml")
+	err := os.WriteFile(path, []byte(`
+current: prod
+profiles:
+  prod:
+    url: https://prod
+    secret: prod-secret
+  dev:
+    url: http://dev
+    output: json
+`), 0644)
+	if err != nil {
+		t.Fatalf("write profile file: %v", err)
+	}
+
+	noCurrent := filepath.Join(dir, "default.yaml")
+	err = os.WriteFile(noCurrent, []byte("profiles:\n  default:\n    url: http://default\n"), 0644)
+	if err != nil {
+		t.Fatalf("write profile file: %v", err)
+	}
+
+	invalid := filepath.Join(dir, "invalid.yaml")
+	err = os.WriteFile(invalid, []byte("profiles: [\n"), 0644)
+	if err != nil {
+		t.Fatalf("write profile file: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		profile string
+		env     map[string]string
+		want    profile
+		err     string
+	}{
+		{"current", path, "", nil, profile{URL: "https://prod", Secret: "prod-secret"}, ""},
+		{"named", path, "dev", nil, profile{URL: "http://dev", Output: "json"}, ""},
+		{"from env", "", "", map[string]string{"CONFIGCTL_CONFIG": path, "CONFIGCTL_PROFILE": "dev"}, profile{URL: "http://dev", Output: "json"}, ""},
+		{"default profile", noCurrent, "", nil, profile{URL: "http://default"}, ""},
+		{"unknown profile", path, "staging", nil, profile{}, `profile "staging" not found`},
+		{"missing explicit file", filepath.Join(dir, "missing.yaml"), "", nil, profile{}, "read profile file"},
+		{"missing default file", "", "", map[string]string{"HOME": dir}, profile{}, ""},
+		{"missing default file with name", "", "prod", map[string]string{"HOME": dir}, profile{}, "read profile file"},
+		{"invalid", invalid, "", nil, profile{}, "parse profile file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CONFIGCTL_CONFIG", "")
+			t.Setenv("CONFIGCTL_PROFILE", "")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			got, err := loadProfile(tt.path, tt.profile)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("load: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"distributed-configuration/internal/controller/repository"
+	"distributed-configuration/internal/controller/service"
+	"distributed-configuration/pkg/utils"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"os"
+)
+
+// runBackup handles `controller backup <file|->`.
+func runBackup(svc service.BackupService, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: controller backup <file|->")
+	}
+
+	if args[0] == "-" {
+		return svc.Export(context.Background(), os.Stdout)
+	}
+
+	// a failed export must not leave a truncated archive behind.
+	return utils.WriteAtomic(args[0], 0644, func(w io.Writer) error {
+		return svc.Export(context.Background(), w)
+	})
+}
+
+// runRestore handles `controller restore [-conflict skip|overwrite|fail] <file|->`.
+func runRestore(svc service.BackupService, args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	conflict := fs.String("conflict", string(repository.ConflictSkip), "skip, overwrite or fail on existing rows")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: controller restore [-conflict skip|overwrite|fail] <file|->")
+	}
+
+	var r io.Reader = os.Stdin
+	if fs.Arg(0) != "-" {
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	res, err := svc.Import(context.Background(), r, repository.ConflictMode(*conflict))
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(os.Stdout).Encode(res)
+}
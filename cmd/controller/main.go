@@ -7,13 +7,11 @@ import (
 	"distributed-configuration/internal/controller/handler"
 	"distributed-configuration/internal/controller/repository"
 	"distributed-configuration/internal/controller/service"
-	model "distributed-configuration/pkg/models"
 	"distributed-configuration/pkg/utils"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
-	"path/filepath"
 	"syscall"
 	"time"
 
@@ -21,9 +19,6 @@ import (
 	httpSwagger "github.com/swaggo/http-swagger"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
-	"gorm.io/driver/sqlite"
-	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
 )
 
 // @title           Distributed Config System API
@@ -50,41 +45,65 @@ func main() {
 		return
 	}
 
-	dbPath := cfg.DBDSN
-	if dbPath == "" {
-		dbPath = "./data/controller/controller.db"
-	}
-
-	dir := filepath.Dir(dbPath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		log.Fatal("Could not create db directory", zap.Error(err))
+	db, err := repository.NewDatabase(cfg.DBDSN, &log)
+	if err != nil {
+		log.Fatal(err.Error())
 		return
 	}
 
-	db, err := gorm.Open(sqlite.Open(dbPath), &gorm.Config{
-		Logger: utils.NewZapGormLogger(log.Logger, logger.Error, time.Duration(10*time.Second)),
-	})
-	if err != nil {
-		log.Fatal(err.Error())
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		err = runMigrate(db, &log, os.Args[2:])
+		if err != nil {
+			log.Fatal(err.Error())
+		}
 		return
 	}
 
-	err = db.AutoMigrate(&model.Configuration{}, &model.Agent{})
+	err = repository.MigrateUp(db, &log)
 	if err != nil {
 		log.Fatal(err.Error())
 		return
 	}
 
-	rds := redis.NewClient(&redis.Options{Addr: cfg.RedisAddr, Password: cfg.RedisPass})
-
 	agentRepo := repository.NewAgentRepository(db, &log)
 	configRepo := repository.NewConfigRepository(db, &log)
+	backupRepo := repository.NewBackupRepository(db, &log)
+
+	rds := redis.NewClient(&redis.Options{Addr: cfg.RedisAddr, Password: cfg.RedisPass})
 
-	agentSvc := service.NewAgentService(&log, agentRepo, cfg)
 	configSvc := service.NewConfigService(&log, configRepo)
-	notif := service.NewRedisNotifier(rds, cfg.ChannelKey, &log)
+	notif := service.NewRedisNotifier(rds, cfg.ChannelKey, configSvc, &log)
+	// a restore from the command line also tells the running replicas.
+	backupSvc := service.NewBackupService(&log, backupRepo, configSvc, notif)
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "backup":
+			err = runBackup(backupSvc, os.Args[2:])
+		case "restore":
+			err = runRestore(backupSvc, os.Args[2:])
+		default:
+			err = fmt.Errorf("unknown command %q", os.Args[1])
+		}
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		return
+	}
+
+	agentSvc := service.NewAgentService(&log, agentRepo, cfg)
+	retentionSvc := service.NewRetentionService(&log, configRepo, agentRepo, cfg)
+	leader := service.NewLeaderElector(rds, cfg.LeaderKey, cfg.ReplicaID, cfg.LeaderTTL, &log)
+
+	handler := handler.NewHandler(configSvc, agentSvc, retentionSvc, backupSvc, leader, &log, cfg, notif)
 
-	handler := handler.NewHandler(configSvc, agentSvc, &log, cfg, notif)
+	// background jobs only run on the elected leader.
+	ctx, cancel := context.WithCancel(context.Background())
+	electionDone := make(chan struct{})
+	go func() {
+		defer close(electionDone)
+		leader.Run(ctx, retentionSvc.Run)
+	}()
 
 	mux := http.NewServeMux()
 
@@ -96,6 +115,62 @@ func main() {
 			),
 		),
 	)
+	mux.Handle(
+		"/admin/config/pin",
+		handler.Authentication(
+			handler.RoleBase(utils.RoleAdmin)(
+				http.HandlerFunc(handler.Pin),
+			),
+		),
+	)
+	mux.Handle(
+		"/admin/config/prune",
+		handler.Authentication(
+			handler.RoleBase(utils.RoleAdmin)(
+				http.HandlerFunc(handler.Prune),
+			),
+		),
+	)
+	mux.Handle(
+		"/admin/config/history",
+		handler.Authentication(
+			handler.RoleBase(utils.RoleAdmin)(
+				http.HandlerFunc(handler.History),
+			),
+		),
+	)
+	mux.Handle(
+		"/admin/config/rollback",
+		handler.Authentication(
+			handler.RoleBase(utils.RoleAdmin)(
+				http.HandlerFunc(handler.Rollback),
+			),
+		),
+	)
+	mux.Handle(
+		"/admin/agents",
+		handler.Authentication(
+			handler.RoleBase(utils.RoleAdmin)(
+				http.HandlerFunc(handler.Agents),
+			),
+		),
+	)
+	mux.Handle(
+		"/admin/backup",
+		handler.Authentication(
+			handler.RoleBase(utils.RoleAdmin)(
+				http.HandlerFunc(handler.Backup),
+			),
+		),
+	)
+	mux.Handle(
+		"/admin/restore",
+		handler.Authentication(
+			handler.RoleBase(utils.RoleAdmin)(
+				http.HandlerFunc(handler.Restore),
+			),
+		),
+	)
 	mux.Handle(
 		"/register",
 		handler.Authentication(
@@ -112,6 +187,7 @@ func main() {
 			),
 		),
 	)
+	mux.HandleFunc("/status", handler.Status)
 	mux.Handle("/swagger/", httpSwagger.WrapHandler)
 
 	server := &http.Server{
@@ -127,6 +203,9 @@ func main() {
 	}()
 
 	shutdown(server, &log)
+
+	cancel()
+	<-electionDone
 }
 
 func shutdown(srv *http.Server, log *utils.Logger) {
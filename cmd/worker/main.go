@@ -5,9 +5,11 @@ import (
 	_ "distributed-configuration/docs/worker"
 	"distributed-configuration/internal/worker/config"
 	"distributed-configuration/internal/worker/handler"
+	"distributed-configuration/internal/worker/repository"
 	"distributed-configuration/internal/worker/service"
 	"distributed-configuration/pkg/utils"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -42,7 +44,14 @@ func main() {
 		return
 	}
 
-	svc := service.NewWorkerService(&log)
+	repo := repository.NewFileStore(&log, cfg.DataPath)
+	svc := service.NewWorkerService(&log, repo, cfg)
+
+	err = svc.Restore(context.Background())
+	if err != nil {
+		log.Error("failed restore persisted config", zap.Error(err))
+	}
+
 	handler := handler.NewHandler(&log, cfg, svc)
 
 	mux := http.NewServeMux()
@@ -63,13 +72,51 @@ func main() {
 			),
 		),
 	)
+	mux.Handle(
+		"/hit/",
+		handler.Authentication(
+			handler.RoleBase(utils.RoleClient)(
+				http.HandlerFunc(handler.Lookup),
+			),
+		),
+	)
+
+	mux.Handle(
+		"/watch",
+		handler.Authentication(
+			handler.RoleBase(utils.RoleClient)(
+				http.HandlerFunc(handler.Watch),
+			),
+		),
+	)
+	mux.Handle(
+		"/version",
+		handler.Authentication(
+			handler.RoleBase(utils.RoleClient)(
+				http.HandlerFunc(handler.Version),
+			),
+		),
+	)
+	mux.Handle(
+		"/history",
+		handler.Authentication(
+			handler.RoleBase(utils.RoleClient)(
+				http.HandlerFunc(handler.History),
+			),
+		),
+	)
 
 	mux.Handle("/swagger/", httpSwagger.WrapHandler)
 
+	// watch requests hold their connection open, end them when shutting
+	// down instead of waiting out the shutdown timeout.
+	baseCtx, cancelWatches := context.WithCancel(context.Background())
 	server := &http.Server{
-		Addr:    fmt.Sprintf(":%d", cfg.HTTPPort),
-		Handler: mux,
+		Addr:        fmt.Sprintf(":%d", cfg.HTTPPort),
+		Handler:     mux,
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
 	}
+	server.RegisterOnShutdown(cancelWatches)
 
 	go func() {
 		log.Info("http server started", zap.Int("addr", cfg.HTTPPort))
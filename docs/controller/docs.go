@@ -15,16 +15,177 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/admin/config": {
-            "post": {
+        "/admin/agents": {
+            "get": {
+                "description": "Admin endpoint listing registered agents. DELETE removes the agent given by agent_id.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "List or remove agents",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Agent to remove (DELETE only)",
+                        "name": "agent_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.Agent"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Agent not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                },
                 "security": [
                     {
                         "BearerAuth": []
                     }
+                ]
+            },
+            "delete": {
+                "description": "Admin endpoint listing registered agents. DELETE removes the agent given by agent_id.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "List or remove agents",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Agent to remove (DELETE only)",
+                        "name": "agent_id",
+                        "in": "query"
+                    }
                 ],
-                "description": "Admin endpoint to update the configuration that will be pushed to all agents",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.Agent"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Agent not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            }
+        },
+        "/admin/backup": {
+            "get": {
+                "description": "Download every configuration version and agent as a gzip compressed JSON archive",
+                "produces": [
+                    "application/gzip"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Export controller state",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            }
+        },
+        "/admin/config": {
+            "get": {
+                "description": "Admin endpoint returning the latest configuration, or the given version, with its metadata",
+                "produces": [
+                    "application/json",
+                    "application/yaml",
+                    "application/toml"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Get a configuration version",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Configuration version (default latest)",
+                        "name": "version",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.ConfigVersion"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid version",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Version not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            },
+            "post": {
+                "description": "Admin endpoint to update the configuration that will be pushed to all agents. YAML and TOML bodies, chosen by Content-Type, are stored as JSON.",
                 "consumes": [
-                    "application/json"
+                    "application/json",
+                    "application/yaml",
+                    "application/toml"
                 ],
                 "produces": [
                     "application/json"
@@ -40,49 +201,91 @@ const docTemplate = `{
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/distributed-configuration_pkg_models.Configuration"
+                            "$ref": "#/definitions/model.Configuration"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only save while this is the latest version (ETag)",
+                        "name": "If-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
-                    "200": {
+                    "201": {
                         "description": "message: config updated",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
                         }
+                    },
+                    "409": {
+                        "description": "Another version was saved concurrently",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "412": {
+                        "description": "If-Match is not the latest version",
+                        "schema": {
+                            "type": "string"
+                        }
                     }
-                }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
             }
         },
-        "/config": {
+        "/admin/config/history": {
             "get": {
+                "description": "Admin endpoint listing every stored version without its data, newest first",
+                "produces": [
+                    "application/json",
+                    "application/yaml",
+                    "application/toml"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "List configuration versions",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.ConfigVersion"
+                            }
+                        }
+                    }
+                },
                 "security": [
                     {
                         "BearerAuth": []
                     }
-                ],
-                "description": "Get the latest config if version has changed. Returns 304 if version matches.",
+                ]
+            }
+        },
+        "/admin/config/pin": {
+            "post": {
+                "description": "Pinned versions are never removed by the retention policy. POST pins, DELETE unpins.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "agent"
+                    "admin"
                 ],
-                "summary": "Poll for latest configuration",
+                "summary": "Pin or unpin a configuration version",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Unique Agent ID",
-                        "name": "X-Agent-ID",
-                        "in": "header",
+                        "type": "integer",
+                        "description": "Configuration version",
+                        "name": "version",
+                        "in": "query",
                         "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Current config version (ETag)",
-                        "name": "If-None-Match",
-                        "in": "header"
                     }
                 ],
                 "responses": {
@@ -93,14 +296,17 @@ const docTemplate = `{
                             "additionalProperties": true
                         }
                     },
-                    "304": {
-                        "description": "Not Modified",
+                    "400": {
+                        "description": "Invalid version",
                         "schema": {
-                            "type": "string"
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
                         }
                     },
-                    "401": {
-                        "description": "Unauthorized",
+                    "404": {
+                        "description": "Version not found",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -108,35 +314,81 @@ const docTemplate = `{
                             }
                         }
                     }
-                }
-            },
-            "post": {
+                },
                 "security": [
                     {
                         "BearerAuth": []
                     }
-                ],
-                "description": "Receive config sent by an agent to store at internal storage",
-                "consumes": [
-                    "application/json"
-                ],
+                ]
+            },
+            "delete": {
+                "description": "Pinned versions are never removed by the retention policy. POST pins, DELETE unpins.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "agent"
+                    "admin"
                 ],
-                "summary": "Receive config",
+                "summary": "Pin or unpin a configuration version",
                 "parameters": [
                     {
-                        "description": "config data",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
+                        "type": "integer",
+                        "description": "Configuration version",
+                        "name": "version",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
                         }
+                    },
+                    "400": {
+                        "description": "Invalid version",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Version not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            }
+        },
+        "/admin/config/prune": {
+            "post": {
+                "description": "Apply the retention policy now. With dry_run=true only lists the versions that would be removed.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Prune configuration versions",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "List without deleting",
+                        "name": "dry_run",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -146,9 +398,59 @@ const docTemplate = `{
                             "type": "object",
                             "additionalProperties": true
                         }
+                    }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            }
+        },
+        "/admin/config/rollback": {
+            "post": {
+                "description": "Admin endpoint saving the data of an earlier version as a new version that is pushed to all agents",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Roll back to a configuration version",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Configuration version to restore",
+                        "name": "version",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "304": {
+                        "description": "Already the latest configuration",
+                        "schema": {
+                            "type": "string"
+                        }
                     },
                     "400": {
-                        "description": "Invalid request body",
+                        "description": "Invalid version",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Version not found",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -156,24 +458,105 @@ const docTemplate = `{
                             }
                         }
                     }
-                }
-            }
-        },
-        "/hit": {
-            "get": {
+                },
                 "security": [
                     {
                         "BearerAuth": []
                     }
+                ]
+            }
+        },
+        "/admin/restore": {
+            "post": {
+                "description": "Import an archive produced by /admin/backup. conflict decides what happens to versions and agents that already exist.",
+                "consumes": [
+                    "application/gzip"
                 ],
-                "description": "Fetch config data for client",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "client"
+                    "admin"
+                ],
+                "summary": "Restore controller state",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "skip (default), overwrite or fail",
+                        "name": "conflict",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.RestoreResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid archive",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Conflicting rows with conflict=fail",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            }
+        },
+        "/config": {
+            "get": {
+                "description": "Get the latest config if version has changed. Returns 304 if version matches.\nWith select only the addressed parts of the config are returned and the ETag covers just those parts, so unrelated changes do not wake the request.",
+                "produces": [
+                    "application/json",
+                    "application/yaml",
+                    "application/toml"
+                ],
+                "tags": [
+                    "agent"
+                ],
+                "summary": "Poll for latest configuration",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Unique Agent ID",
+                        "name": "X-Agent-ID",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Current config version (ETag)",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "JSON Pointer into the config, e.g. /database (repeatable)",
+                        "name": "select",
+                        "in": "query"
+                    }
                 ],
-                "summary": "Fetch config data",
                 "responses": {
                     "200": {
                         "description": "OK",
@@ -182,6 +565,21 @@ const docTemplate = `{
                             "additionalProperties": true
                         }
                     },
+                    "304": {
+                        "description": "Not Modified",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid selection",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
                     "401": {
                         "description": "Unauthorized",
                         "schema": {
@@ -190,17 +588,29 @@ const docTemplate = `{
                                 "type": "string"
                             }
                         }
+                    },
+                    "404": {
+                        "description": "Unknown agent, it has to register again",
+                        "schema": {
+                            "type": "string"
+                        },
+                        "headers": {
+                            "X-Agent-Unknown": {
+                                "type": "string",
+                                "description": "true when the agent is not registered"
+                            }
+                        }
                     }
-                }
-            }
-        },
-        "/register": {
-            "post": {
+                },
                 "security": [
                     {
                         "BearerAuth": []
                     }
-                ],
+                ]
+            }
+        },
+        "/register": {
+            "post": {
                 "description": "Register an agent to get a unique ID and polling configuration",
                 "consumes": [
                     "application/json"
@@ -219,7 +629,7 @@ const docTemplate = `{
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/distributed-configuration_pkg_models.AgentRequest"
+                            "$ref": "#/definitions/model.AgentRequest"
                         }
                     }
                 ],
@@ -240,14 +650,111 @@ const docTemplate = `{
                             }
                         }
                     }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            },
+            "delete": {
+                "description": "Remove an agent that is shutting down for good",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "agent"
+                ],
+                "summary": "Deregister an agent",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Unique Agent ID",
+                        "name": "X-Agent-ID",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Agent not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            }
+        },
+        "/status": {
+            "get": {
+                "description": "Report this controller replica and whether it is the cluster leader",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "cluster"
+                ],
+                "summary": "Replica status",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.ReplicaStatus"
+                        }
+                    }
                 }
             }
         }
     },
     "definitions": {
-        "distributed-configuration_pkg_models.AgentRequest": {
+        "model.Agent": {
+            "type": "object",
+            "properties": {
+                "agent_id": {
+                    "type": "string"
+                },
+                "config_version": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "host": {
+                    "type": "string"
+                },
+                "last_seen": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "poll_interval_seconds": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.AgentRequest": {
             "type": "object",
             "properties": {
+                "etag": {
+                    "description": "ETag is the config the agent already applied, sent when it registers\nagain after the controller forgot it.",
+                    "type": "string"
+                },
                 "host": {
                     "type": "string"
                 },
@@ -256,11 +763,59 @@ const docTemplate = `{
                 }
             }
         },
-        "distributed-configuration_pkg_models.Configuration": {
+        "model.ConfigVersion": {
             "type": "object",
             "properties": {
+                "created_at": {
+                    "type": "string"
+                },
                 "data": {
                     "type": "object"
+                },
+                "hash": {
+                    "type": "string"
+                },
+                "pinned": {
+                    "type": "boolean"
+                },
+                "version": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.Configuration": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "object"
+                }
+            }
+        },
+        "model.ReplicaStatus": {
+            "type": "object",
+            "properties": {
+                "leader_id": {
+                    "type": "string"
+                },
+                "replica_id": {
+                    "type": "string"
+                },
+                "role": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.RestoreResult": {
+            "type": "object",
+            "properties": {
+                "inserted": {
+                    "type": "integer"
+                },
+                "skipped": {
+                    "type": "integer"
+                },
+                "updated": {
+                    "type": "integer"
                 }
             }
         }
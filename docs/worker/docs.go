@@ -15,14 +15,9 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/admin/config": {
+        "/agent-config": {
             "post": {
-                "security": [
-                    {
-                        "BearerAuth": []
-                    }
-                ],
-                "description": "Admin endpoint to update the configuration that will be pushed to all agents",
+                "description": "Receive config sent by an agent to store at internal storage",
                 "consumes": [
                     "application/json"
                 ],
@@ -30,57 +25,125 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "admin"
+                    "agent"
                 ],
-                "summary": "Update global configuration",
+                "summary": "Receive config",
                 "parameters": [
                     {
-                        "description": "New Configuration",
-                        "name": "config",
+                        "type": "string",
+                        "description": "Config version (ETag) the agent delivers",
+                        "name": "X-Config-Version",
+                        "in": "header"
+                    },
+                    {
+                        "description": "config data",
+                        "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/distributed-configuration_pkg_models.Configuration"
+                            "type": "object",
+                            "additionalProperties": true
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "message: config updated",
+                        "description": "OK",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
                         }
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
                     }
-                }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
             }
         },
-        "/config": {
+        "/history": {
             "get": {
+                "description": "The last versions this worker received, newest first. With revision the full config of that version is returned.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "client"
+                ],
+                "summary": "Config history",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Revision to return in full",
+                        "name": "revision",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Revision not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                },
                 "security": [
                     {
                         "BearerAuth": []
                     }
-                ],
-                "description": "Get the latest config if version has changed. Returns 304 if version matches.",
+                ]
+            }
+        },
+        "/hit": {
+            "get": {
+                "description": "Fetch config data for client. The version is returned in the ETag header, send it back as If-None-Match to get 304 while it is unchanged. /hit?key=database.host\u0026key=/cache/ttl returns only an object keyed by the requested keys (dotted paths or JSON Pointers), missing keys answer 404.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "agent"
+                    "client"
                 ],
-                "summary": "Poll for latest configuration",
+                "summary": "Fetch config data",
                 "parameters": [
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Dotted path or JSON Pointer (repeatable)",
+                        "name": "key",
+                        "in": "query"
+                    },
                     {
                         "type": "string",
-                        "description": "Unique Agent ID",
-                        "name": "X-Agent-ID",
-                        "in": "header",
-                        "required": true
+                        "description": "Convert values to string, int, float or bool",
+                        "name": "type",
+                        "in": "query"
                     },
                     {
                         "type": "string",
-                        "description": "Current config version (ETag)",
+                        "description": "Cached config version (ETag)",
                         "name": "If-None-Match",
                         "in": "header"
                     }
@@ -99,6 +162,15 @@ const docTemplate = `{
                             "type": "string"
                         }
                     },
+                    "400": {
+                        "description": "Value does not convert to type",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
                     "401": {
                         "description": "Unauthorized",
                         "schema": {
@@ -107,48 +179,93 @@ const docTemplate = `{
                                 "type": "string"
                             }
                         }
+                    },
+                    "404": {
+                        "description": "Key not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
                     }
-                }
-            },
-            "post": {
+                },
                 "security": [
                     {
                         "BearerAuth": []
                     }
-                ],
-                "description": "Receive config sent by an agent to store at internal storage",
-                "consumes": [
-                    "application/json"
-                ],
+                ]
+            }
+        },
+        "/hit/{path}": {
+            "get": {
+                "description": "Fetch the single value at a JSON Pointer instead of the whole document, /hit/database/host returns the value of database.host. A missing key answers 404.",
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "text/plain"
                 ],
                 "tags": [
-                    "agent"
+                    "client"
                 ],
-                "summary": "Receive config",
+                "summary": "Fetch config value",
                 "parameters": [
                     {
-                        "description": "config data",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "type": "object",
-                            "additionalProperties": true
-                        }
+                        "type": "string",
+                        "description": "JSON Pointer below /hit",
+                        "name": "path",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Convert the value to string, int, float or bool",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "text returns the value as plain text",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cached config version (ETag)",
+                        "name": "If-None-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
+                        "schema": {}
+                    },
+                    "304": {
+                        "description": "Not Modified",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": true
+                            "type": "string"
                         }
                     },
                     "400": {
-                        "description": "Invalid request body",
+                        "description": "Value does not convert to type",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Key not found",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -156,34 +273,33 @@ const docTemplate = `{
                             }
                         }
                     }
-                }
-            }
-        },
-        "/hit": {
-            "get": {
+                },
                 "security": [
                     {
                         "BearerAuth": []
                     }
-                ],
-                "description": "Fetch config data for client",
+                ]
+            }
+        },
+        "/version": {
+            "get": {
+                "description": "Revision, hash and receive time of the configuration this worker serves",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "client"
                 ],
-                "summary": "Fetch config data",
+                "summary": "Served config version",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": true
+                            "$ref": "#/definitions/model.ConfigRecord"
                         }
                     },
-                    "401": {
-                        "description": "Unauthorized",
+                    "404": {
+                        "description": "No configuration yet",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -191,36 +307,43 @@ const docTemplate = `{
                             }
                         }
                     }
-                }
-            }
-        },
-        "/register": {
-            "post": {
+                },
                 "security": [
                     {
                         "BearerAuth": []
                     }
-                ],
-                "description": "Register an agent to get a unique ID and polling configuration",
-                "consumes": [
-                    "application/json"
-                ],
+                ]
+            }
+        },
+        "/watch": {
+            "get": {
+                "description": "Long-poll that returns the config as soon as its version differs from If-None-Match, or 304 once timeout passes without a change.\nWith Accept: text/event-stream (or stream=sse) every new version is streamed as a server-sent event named config, its id is the version.",
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "text/event-stream"
                 ],
                 "tags": [
-                    "agent"
+                    "client"
                 ],
-                "summary": "Register a new agent",
+                "summary": "Watch config changes",
                 "parameters": [
                     {
-                        "description": "Agent Registration Info",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/distributed-configuration_pkg_models.AgentRequest"
-                        }
+                        "type": "string",
+                        "description": "Cached config version (ETag)",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Long-poll timeout, e.g. 30s (default 60s, at most 5m)",
+                        "name": "timeout",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "sse to stream changes",
+                        "name": "stream",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -231,36 +354,39 @@ const docTemplate = `{
                             "additionalProperties": true
                         }
                     },
-                    "400": {
-                        "description": "Invalid request body",
+                    "304": {
+                        "description": "Not Modified",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "type": "string"
                         }
                     }
-                }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
             }
         }
     },
     "definitions": {
-        "distributed-configuration_pkg_models.AgentRequest": {
+        "model.ConfigRecord": {
             "type": "object",
             "properties": {
-                "host": {
+                "config": {
+                    "type": "object"
+                },
+                "etag": {
                     "type": "string"
                 },
-                "name": {
+                "hash": {
+                    "type": "string"
+                },
+                "revision": {
+                    "type": "integer"
+                },
+                "updated_at": {
                     "type": "string"
-                }
-            }
-        },
-        "distributed-configuration_pkg_models.Configuration": {
-            "type": "object",
-            "properties": {
-                "data": {
-                    "type": "object"
                 }
             }
         }
@@ -10,19 +10,33 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
 
 	"go.uber.org/zap"
 )
 
 type ControllerClient interface {
-	Register(ctx context.Context, agentName, hostname string) (model.AgentResponse, error)
+	Register(ctx context.Context, agentName, hostname, etag string) (model.AgentResponse, error)
 	FetchConfig(ctx context.Context, agentID, etag, pollUrl string) (model.ConfigResponse, error)
+	Deregister(ctx context.Context, agentID string) error
+	Endpoint() string
+	UseEndpoint(url string)
 }
 
+// controllerClient sticks to one controller endpoint and fails over to the
+// next healthy one when a request fails.
 type controllerClient struct {
-	log        *utils.Logger
-	cfg        *config.Config
-	httpClient *http.Client
+	log          *utils.Logger
+	cfg          *config.Config
+	httpClient   *http.Client
+	healthClient *http.Client
+
+	mu        sync.RWMutex
+	endpoints []string
+	current   int
 }
 
 func NewControllerClient(log *utils.Logger, cfg *config.Config) ControllerClient {
@@ -32,20 +46,100 @@ func NewControllerClient(log *utils.Logger, cfg *config.Config) ControllerClient
 		httpClient: &http.Client{
 			Timeout: cfg.Timeout,
 		},
+		healthClient: &http.Client{
+			Timeout: 3 * time.Second,
+		},
+		endpoints: cfg.ControllerUrls,
+	}
+}
+
+func (c *controllerClient) Endpoint() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if len(c.endpoints) == 0 {
+		return ""
+	}
+	return c.endpoints[c.current]
+}
+
+// UseEndpoint switches to url when it is one of the configured endpoints,
+// e.g. to resume against the controller used before a restart.
+func (c *controllerClient) UseEndpoint(url string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, endpoint := range c.endpoints {
+		if endpoint == url {
+			c.current = i
+			return
+		}
+	}
+}
+
+// failover moves away from the endpoint that just failed to the first
+// other endpoint passing a health check. It stays put when none is healthy
+// so the caller keeps backing off.
+func (c *controllerClient) failover(ctx context.Context, failed string) {
+	c.mu.RLock()
+	endpoints := c.endpoints
+	start := c.current
+	c.mu.RUnlock()
+
+	if len(endpoints) < 2 || endpoints[start] != failed {
+		return
+	}
+
+	next := -1
+	for i := 1; i < len(endpoints); i++ {
+		idx := (start + i) % len(endpoints)
+		if c.healthy(ctx, endpoints[idx]) {
+			next = idx
+			break
+		}
+	}
+	if next < 0 {
+		c.log.Warn("no healthy controller to fail over to", zap.String("current", failed))
+		return
+	}
+
+	c.mu.Lock()
+	if c.current == start {
+		c.current = next
+	}
+	c.mu.Unlock()
+
+	c.log.Warn("controller failover", zap.String("from", failed), zap.String("to", endpoints[next]))
+}
+
+func (c *controllerClient) healthy(ctx context.Context, endpoint string) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"/status", nil)
+	if err != nil {
+		return false
+	}
+
+	resp, err := c.healthClient.Do(req)
+	if err != nil {
+		return false
 	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode == http.StatusOK
 }
 
-func (c *controllerClient) Register(ctx context.Context, agentName, hostname string) (model.AgentResponse, error) {
+func (c *controllerClient) Register(ctx context.Context, agentName, hostname, etag string) (model.AgentResponse, error) {
 	var res model.AgentResponse
 
-	payload := map[string]any{
-		"name": agentName,
-		"host": hostname,
+	payload := model.AgentRequest{
+		Name: agentName,
+		Host: hostname,
+		ETag: etag,
 	}
 
 	body, _ := json.Marshal(payload)
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.cfg.ControllerUrl+"/register", bytes.NewBuffer(body))
+	endpoint := c.Endpoint()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint+"/register", bytes.NewBuffer(body))
 	if err != nil {
 		c.log.Error("failed create new request", zap.Error(err))
 		return model.AgentResponse{}, err
@@ -57,10 +151,17 @@ func (c *controllerClient) Register(ctx context.Context, agentName, hostname str
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		c.log.Error("network error, failed to register", zap.Error(err))
+		if ctx.Err() == nil {
+			c.failover(ctx, endpoint)
+		}
 		return model.AgentResponse{}, err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode >= http.StatusInternalServerError {
+		c.failover(ctx, endpoint)
+	}
+
 	if resp.StatusCode != http.StatusCreated {
 		errBody, _ := io.ReadAll(resp.Body)
 		c.log.Error(string(errBody))
@@ -76,10 +177,44 @@ func (c *controllerClient) Register(ctx context.Context, agentName, hostname str
 	return res, nil
 }
 
+func (c *controllerClient) Deregister(ctx context.Context, agentID string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, c.Endpoint()+"/register", nil)
+	if err != nil {
+		c.log.Error("failed create new request", zap.Error(err))
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.cfg.ControllerSecret)
+	req.Header.Set("X-Agent-ID", agentID)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		c.log.Error("network error, failed to deregister", zap.Error(err))
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		errBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("deregister failed (status %d): %s", resp.StatusCode, string(errBody))
+	}
+
+	return nil
+}
+
 func (c *controllerClient) FetchConfig(ctx context.Context, agentID, etag, pollUrl string) (model.ConfigResponse, error) {
 	var res model.ConfigResponse
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.cfg.ControllerUrl+pollUrl, nil)
+	// only the selected parts are served and they alone decide the etag.
+	if len(c.cfg.Select) > 0 {
+		sep := "?"
+		if strings.Contains(pollUrl, "?") {
+			sep = "&"
+		}
+		pollUrl += sep + url.Values{"select": c.cfg.Select}.Encode()
+	}
+
+	endpoint := c.Endpoint()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+pollUrl, nil)
 	if err != nil {
 		c.log.Error("failed create new request", zap.Error(err))
 		return model.ConfigResponse{}, err
@@ -96,10 +231,17 @@ func (c *controllerClient) FetchConfig(ctx context.Context, agentID, etag, pollU
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		c.log.Error("network error, failed to get config", zap.Error(err))
+		if ctx.Err() == nil {
+			c.failover(ctx, endpoint)
+		}
 		return model.ConfigResponse{}, err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode >= http.StatusInternalServerError {
+		c.failover(ctx, endpoint)
+	}
+
 	if resp.StatusCode == http.StatusNotModified {
 		c.log.Warn("data not modified", zap.Int("code", resp.StatusCode))
 		return model.ConfigResponse{}, nil
@@ -108,6 +250,9 @@ func (c *controllerClient) FetchConfig(ctx context.Context, agentID, etag, pollU
 	if resp.StatusCode != http.StatusOK {
 		errBody, _ := io.ReadAll(resp.Body)
 		c.log.Error(string(errBody))
+		if resp.StatusCode == http.StatusNotFound && resp.Header.Get("X-Agent-Unknown") == "true" {
+			return model.ConfigResponse{}, utils.ErrUnknownAgent
+		}
 		return model.ConfigResponse{}, fmt.Errorf("poll failed (status %d): %s", resp.StatusCode, string(errBody))
 	}
 
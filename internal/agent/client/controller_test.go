@@ -0,0 +1,49 @@
+package client
+
+import (
+	"context"
+	"distributed-configuration/internal/agent/config"
+	"distributed-configuration/pkg/utils"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestFetchConfigUnknownAgent(t *testing.T) {
+	tests := []struct {
+		name    string
+		unknown bool
+	}{
+		{"marked", true},
+		{"plain 404", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.unknown {
+					w.Header().Set("X-Agent-Unknown", "true")
+				}
+				// a proxy may answer 404 with the same text, only the
+				// header counts.
+				http.Error(w, "unknown agent", http.StatusNotFound)
+			}))
+			defer srv.Close()
+
+			cfg := &config.Config{ControllerUrls: []string{srv.URL}, Timeout: time.Second}
+			client := NewControllerClient(&utils.Logger{Logger: zap.NewNop()}, cfg)
+
+			_, err := client.FetchConfig(context.Background(), "agent-1", "v1", "/config")
+			if err == nil {
+				t.Fatal("fetch succeeded, want an error")
+			}
+			if got := errors.Is(err, utils.ErrUnknownAgent); got != tt.unknown {
+				t.Fatalf("err = %v, unknown agent = %t, want %t", err, got, tt.unknown)
+			}
+		})
+	}
+}
@@ -14,7 +14,7 @@ import (
 )
 
 type WorkerClient interface {
-	PushConfig(ctx context.Context, config json.RawMessage) error
+	PushConfig(ctx context.Context, workerUrl, etag string, config json.RawMessage) error
 }
 
 type workerClient struct {
@@ -33,8 +33,8 @@ func NewWorkerClient(log *utils.Logger, cfg *config.Config) WorkerClient {
 	}
 }
 
-func (c *workerClient) PushConfig(ctx context.Context, config json.RawMessage) error {
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.cfg.WorkerUrl, bytes.NewBuffer(config))
+func (c *workerClient) PushConfig(ctx context.Context, workerUrl, etag string, config json.RawMessage) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, workerUrl, bytes.NewBuffer(config))
 	if err != nil {
 		c.log.Error("failed create new request", zap.Error(err))
 		return err
@@ -42,6 +42,7 @@ func (c *workerClient) PushConfig(ctx context.Context, config json.RawMessage) e
 
 	req.Header.Set("Authorization", "Bearer "+c.cfg.WorkerSecret)
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Config-Version", etag)
 	c.log.Info("header request", zap.Any("value", req.Header))
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
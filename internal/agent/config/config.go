@@ -1,6 +1,7 @@
 package config
 
 import (
+	"distributed-configuration/pkg/utils"
 	"fmt"
 	"time"
 
@@ -13,8 +14,18 @@ type Config struct {
 	ControllerSecret string        `env:"CONTROLLER_SECRET"`
 	WorkerSecret     string        `env:"WORKER_SECRET"`
 	ControllerUrl    string        `env:"CONTROLLER_URL"`
+	ControllerUrls   []string      `env:"CONTROLLER_URLS" envSeparator:","`
 	WorkerUrl        string        `env:"WORKER_URL"`
+	WorkerUrls       []string      `env:"WORKER_URLS" envSeparator:","`
+	Sinks            []string      `env:"SINKS" envSeparator:","`
+	Select           []string      `env:"CONFIG_SELECT" envSeparator:","`
+	Hooks            []string      `env:"HOOKS" envSeparator:","`
+	HookRevert       bool          `env:"HOOK_REVERT_ON_FAILURE"`
 	FilePath         string        `env:"FILE_PATH"`
+	APIAddr          string        `env:"AGENT_API_ADDR"`
+	APISecret        string        `env:"AGENT_API_SECRET"`
+	ShutdownTimeout  time.Duration `env:"SHUTDOWN_TIMEOUT"`
+	Deregister       bool          `env:"DEREGISTER_ON_SHUTDOWN"`
 	Timeout          time.Duration `env:"TIMEOUT"`
 }
 
@@ -26,5 +37,23 @@ func NewConfig() (*Config, error) {
 		return nil, fmt.Errorf("read env error: %w", err)
 	}
 
+	if len(cfg.ControllerUrls) == 0 && cfg.ControllerUrl != "" {
+		cfg.ControllerUrls = []string{cfg.ControllerUrl}
+	}
+	if len(cfg.WorkerUrls) == 0 && cfg.WorkerUrl != "" {
+		cfg.WorkerUrls = []string{cfg.WorkerUrl}
+	}
+
+	if cfg.ShutdownTimeout <= 0 {
+		cfg.ShutdownTimeout = 10 * time.Second
+	}
+
+	for _, pointer := range cfg.Select {
+		_, err = utils.ParsePointer(pointer)
+		if err != nil {
+			return nil, fmt.Errorf("read env error: CONFIG_SELECT: %w", err)
+		}
+	}
+
 	return &cfg, nil
 }
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"distributed-configuration/internal/agent/config"
+	"distributed-configuration/internal/agent/service"
+	"distributed-configuration/pkg/utils"
+	"net/http"
+)
+
+type handler struct {
+	svc *service.AgentService
+	cfg *config.Config
+	log *utils.Logger
+}
+
+func NewHandler(log *utils.Logger, cfg *config.Config, svc *service.AgentService) *handler {
+	return &handler{
+		svc: svc,
+		cfg: cfg,
+		log: log,
+	}
+}
+
+// Status godoc
+// @Summary      Agent status
+// @Description  Agent id, current ETag, last poll time and error, and the delivery state of every sink
+// @Tags         agent
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  model.AgentStatus
+// @Router       /status [get]
+func (h handler) Status(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, h.svc.Status())
+}
+
+// Config godoc
+// @Summary      Cached configuration
+// @Description  The configuration the agent currently delivers, its version is in the ETag header
+// @Tags         agent
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  map[string]interface{}
+// @Failure      404  {object}  map[string]string "No config received yet"
+// @Router       /config [get]
+func (h handler) Config(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	etag, config := h.svc.Config()
+	if config == nil {
+		http.Error(w, "no config received yet", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag)
+	w.WriteHeader(http.StatusOK)
+	w.Write(config)
+}
+
+// Resync godoc
+// @Summary      Force resync
+// @Description  Interrupt the running long-poll and fetch the configuration from the controller again
+// @Tags         control
+// @Produce      json
+// @Security     BearerAuth
+// @Success      202  {object}  map[string]interface{}
+// @Router       /resync [post]
+func (h handler) Resync(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.svc.Resync()
+
+	resp := map[string]any{
+		"status":  "accepted",
+		"message": "resync started",
+	}
+	utils.WriteJSON(w, http.StatusAccepted, resp)
+}
+
+// Register godoc
+// @Summary      Re-register
+// @Description  Drop the agent id and register with the controller again
+// @Tags         control
+// @Produce      json
+// @Security     BearerAuth
+// @Success      202  {object}  map[string]interface{}
+// @Router       /register [post]
+func (h handler) Register(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.svc.Reregister()
+
+	resp := map[string]any{
+		"status":  "accepted",
+		"message": "re-register started",
+	}
+	utils.WriteJSON(w, http.StatusAccepted, resp)
+}
+
+// Push godoc
+// @Summary      Push cached configuration
+// @Description  Deliver the cached configuration to every worker and sink again and report the outcome per sink
+// @Tags         control
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  map[string]interface{}
+// @Failure      404  {object}  map[string]string "No config received yet"
+// @Router       /push [post]
+func (h handler) Push(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if _, config := h.svc.Config(); config == nil {
+		http.Error(w, "no config received yet", http.StatusNotFound)
+		return
+	}
+
+	resp := map[string]any{
+		"status":     "success",
+		"deliveries": h.svc.Push(r.Context()),
+	}
+	utils.WriteJSON(w, http.StatusOK, resp)
+}
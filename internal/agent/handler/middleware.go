@@ -0,0 +1,24 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Authentication requires AGENT_API_SECRET as bearer token when it is set,
+// otherwise the api relies on being bound to a local address or socket.
+func (h handler) Authentication(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.cfg.APISecret == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token != h.cfg.APISecret {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,87 @@
+package hook
+
+import (
+	"bytes"
+	"context"
+	model "distributed-configuration/pkg/models"
+	"distributed-configuration/pkg/utils"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type execHook struct {
+	log     *utils.Logger
+	command string
+	args    []string
+	timeout time.Duration
+}
+
+// NewExecHook runs a command with CONFIG_ETAG set, killing it after the
+// timeout.
+func NewExecHook(log *utils.Logger, u *url.URL) (Hook, error) {
+	if u.Path == "" {
+		return nil, fmt.Errorf("missing command")
+	}
+
+	timeout, err := timeoutParam(u, 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	return &execHook{
+		log:     log,
+		command: u.Path,
+		args:    u.Query()["arg"],
+		timeout: timeout,
+	}, nil
+}
+
+// Name includes the arguments, the same command run with different
+// arguments is a different hook.
+func (h *execHook) Name() string {
+	name := "exec://" + h.command
+	if len(h.args) > 0 {
+		name += "?" + url.Values{"arg": h.args}.Encode()
+	}
+	return name
+}
+
+func (h *execHook) Run(ctx context.Context, etag string) model.HookResult {
+	res := result(h.Name(), etag)
+
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, h.command, h.args...)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	cmd.Env = append(os.Environ(), "CONFIG_ETAG="+etag)
+
+	err := cmd.Run()
+	res.Output = truncate(output.Bytes())
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			res.ExitCode = exitErr.ExitCode()
+		}
+		h.log.Error(
+			"reload command failed",
+			zap.String("command", h.command),
+			zap.Int("exit_code", res.ExitCode),
+			zap.String("output", res.Output),
+			zap.Error(err),
+		)
+		res.Error = err.Error()
+		return res
+	}
+
+	res.Success = true
+	return res
+}
@@ -0,0 +1,77 @@
+package hook
+
+import (
+	"context"
+	"distributed-configuration/internal/agent/config"
+	model "distributed-configuration/pkg/models"
+	"distributed-configuration/pkg/utils"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+const maxOutput = 4096
+
+// Hook notifies the consuming process after a new config was delivered.
+type Hook interface {
+	Name() string
+	Run(ctx context.Context, etag string) model.HookResult
+}
+
+// New builds the hooks listed in HOOKS. Every entry is a URL whose scheme
+// selects the hook:
+//
+//	signal:///var/run/nginx.pid?signal=HUP               signal the pid in a pidfile
+//	exec:///usr/sbin/nginx?arg=-s&arg=reload&timeout=10s run a command
+//	http://127.0.0.1:9090/-/reload?method=POST           call an HTTP endpoint
+func New(log *utils.Logger, cfg *config.Config) ([]Hook, error) {
+	hooks := make([]Hook, 0, len(cfg.Hooks))
+	for _, spec := range cfg.Hooks {
+		u, err := url.Parse(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hook %q: %w", spec, err)
+		}
+
+		var h Hook
+		switch u.Scheme {
+		case "signal":
+			h, err = NewSignalHook(log, u)
+		case "exec":
+			h, err = NewExecHook(log, u)
+		case "http", "https":
+			h, err = NewHTTPHook(log, u)
+		default:
+			err = fmt.Errorf("unknown scheme %q", u.Scheme)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid hook %q: %w", spec, err)
+		}
+		hooks = append(hooks, h)
+	}
+
+	return hooks, nil
+}
+
+func timeoutParam(u *url.URL, def time.Duration) (time.Duration, error) {
+	t := u.Query().Get("timeout")
+	if t == "" {
+		return def, nil
+	}
+
+	d, err := time.ParseDuration(t)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timeout: %w", err)
+	}
+	return d, nil
+}
+
+func truncate(output []byte) string {
+	if len(output) > maxOutput {
+		return string(output[:maxOutput]) + "...(truncated)"
+	}
+	return string(output)
+}
+
+func result(name, etag string) model.HookResult {
+	return model.HookResult{Name: name, ETag: etag, RanAt: time.Now()}
+}
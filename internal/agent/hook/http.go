@@ -0,0 +1,86 @@
+package hook
+
+import (
+	"context"
+	model "distributed-configuration/pkg/models"
+	"distributed-configuration/pkg/utils"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type httpHook struct {
+	log        *utils.Logger
+	url        string
+	method     string
+	httpClient *http.Client
+}
+
+// NewHTTPHook calls an endpoint, any 2xx response counts as success. The
+// method and timeout query parameters are consumed by the agent and not
+// sent.
+func NewHTTPHook(log *utils.Logger, u *url.URL) (Hook, error) {
+	timeout, err := timeoutParam(u, 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	method := strings.ToUpper(u.Query().Get("method"))
+	if method == "" {
+		method = http.MethodPost
+	}
+
+	target := *u
+	query := target.Query()
+	query.Del("method")
+	query.Del("timeout")
+	target.RawQuery = query.Encode()
+
+	return &httpHook{
+		log:        log,
+		url:        target.String(),
+		method:     method,
+		httpClient: &http.Client{Timeout: timeout},
+	}, nil
+}
+
+func (h *httpHook) Name() string {
+	return h.url
+}
+
+func (h *httpHook) Run(ctx context.Context, etag string) model.HookResult {
+	res := result(h.Name(), etag)
+
+	req, err := http.NewRequestWithContext(ctx, h.method, h.url, nil)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	req.Header.Set("X-Config-ETag", etag)
+
+	resp, err := h.httpClient.Do(req)
+	if err != nil {
+		h.log.Error("reload endpoint failed", zap.String("url", h.url), zap.Error(err))
+		res.Error = err.Error()
+		return res
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxOutput+1))
+	res.Output = truncate(body)
+	res.ExitCode = resp.StatusCode
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		h.log.Error("reload endpoint failed", zap.String("url", h.url), zap.Int("status", resp.StatusCode))
+		res.Error = fmt.Sprintf("status %d", resp.StatusCode)
+		return res
+	}
+
+	res.Success = true
+	return res
+}
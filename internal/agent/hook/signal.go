@@ -0,0 +1,86 @@
+//go:build unix
+
+package hook
+
+import (
+	"context"
+	model "distributed-configuration/pkg/models"
+	"distributed-configuration/pkg/utils"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"go.uber.org/zap"
+)
+
+var signals = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"TERM": syscall.SIGTERM,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+}
+
+type signalHook struct {
+	log     *utils.Logger
+	pidfile string
+	signal  syscall.Signal
+}
+
+// NewSignalHook sends a signal (SIGHUP by default) to the process whose pid
+// is in the pidfile.
+func NewSignalHook(log *utils.Logger, u *url.URL) (Hook, error) {
+	if u.Path == "" {
+		return nil, fmt.Errorf("missing pidfile")
+	}
+
+	name := strings.TrimPrefix(strings.ToUpper(u.Query().Get("signal")), "SIG")
+	if name == "" {
+		name = "HUP"
+	}
+	sig, ok := signals[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported signal %q", name)
+	}
+
+	return &signalHook{log: log, pidfile: u.Path, signal: sig}, nil
+}
+
+func (h *signalHook) Name() string {
+	return "signal://" + h.pidfile
+}
+
+func (h *signalHook) Run(ctx context.Context, etag string) model.HookResult {
+	res := result(h.Name(), etag)
+
+	err := h.send()
+	if err != nil {
+		h.log.Error("reload signal failed", zap.String("pidfile", h.pidfile), zap.Error(err))
+		res.Error = err.Error()
+		return res
+	}
+
+	res.Success = true
+	return res
+}
+
+func (h *signalHook) send() error {
+	data, err := os.ReadFile(h.pidfile)
+	if err != nil {
+		return err
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return fmt.Errorf("invalid pid in %s: %w", h.pidfile, err)
+	}
+
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return proc.Signal(h.signal)
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package hook
+
+import (
+	"distributed-configuration/pkg/utils"
+	"errors"
+	"net/url"
+)
+
+// NewSignalHook fails where the process has no unix signals to send.
+func NewSignalHook(log *utils.Logger, u *url.URL) (Hook, error) {
+	return nil, errors.New("signal hooks are only supported on unix")
+}
@@ -1,40 +1,187 @@
 package repository
 
 import (
+	"bytes"
+	"crypto/sha256"
 	model "distributed-configuration/pkg/models"
 	"distributed-configuration/pkg/utils"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"sync"
 
 	"go.uber.org/zap"
 )
 
+// FileStore persists the agent state crash-safely: every save goes to a temp
+// file that is fsynced and renamed over the state file, the content carries
+// a checksum, and the previous generation is kept next to it as a fallback.
+// A lock file keeps a second agent process off the same state.
 type FileStore struct {
 	log      *utils.Logger
 	filepath string
+	lock     *os.File
+
+	// valid is set once the state file on disk is known to be intact, only
+	// then it is rotated into the previous generation.
+	mu    sync.Mutex
+	valid bool
 }
 
-func NewFileStore(log *utils.Logger, filepath string) *FileStore {
-	return &FileStore{log: log, filepath: filepath}
+// errLocked is returned by lockFile when another process holds the lock.
+var errLocked = errors.New("file is locked")
+
+// stateFile is the on-disk layout, Checksum is the sha256 of the State bytes
+// exactly as written.
+type stateFile struct {
+	Checksum string          `json:"checksum"`
+	State    json.RawMessage `json:"state"`
+}
+
+func NewFileStore(log *utils.Logger, filepath string) (*FileStore, error) {
+	r := &FileStore{log: log, filepath: filepath}
+
+	err := r.acquireLock()
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *FileStore) acquireLock() error {
+	err := os.MkdirAll(filepath.Dir(r.filepath), 0755)
+	if err != nil {
+		return err
+	}
+
+	lock, err := os.OpenFile(r.filepath+".lock", os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+
+	err = lockFile(lock)
+	if err != nil {
+		lock.Close()
+		if errors.Is(err, errLocked) {
+			return fmt.Errorf("state file %s is used by another agent process", r.filepath)
+		}
+		return fmt.Errorf("lock state file: %w", err)
+	}
+
+	r.lock = lock
+	return nil
+}
+
+// Close releases the lock on the state file.
+func (r *FileStore) Close() error {
+	if r.lock == nil {
+		return nil
+	}
+
+	unlockFile(r.lock)
+	return r.lock.Close()
 }
 
 func (r *FileStore) Save(state *model.AgentState) error {
-	data, _ := json.MarshalIndent(state, "", " ")
-	return os.WriteFile(r.filepath, data, 0644)
+	data, err := json.MarshalIndent(state, " ", " ")
+	if err != nil {
+		r.log.Error("failed encode agent state", zap.Error(err))
+		return err
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "{\n \"checksum\": %q,\n \"state\": %s\n}\n", checksum(data), data)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.valid {
+		err = r.rotate()
+		if err != nil {
+			r.log.Warn("failed keep previous agent state", zap.Error(err))
+		}
+	}
+
+	err = utils.WriteFileAtomic(r.filepath, buf.Bytes(), 0644)
+	if err != nil {
+		r.log.Error("failed write agent state", zap.String("path", r.filepath), zap.Error(err))
+		return err
+	}
+
+	r.valid = true
+	return nil
 }
 
-func (r *FileStore) Load(state *model.AgentState) error {
-	data, err := os.ReadFile(r.filepath)
+// rotate hard links the current state file as the previous generation, so
+// the state file itself is never missing.
+func (r *FileStore) rotate() error {
+	prev := r.filepath + ".prev"
+	tmp := prev + ".tmp"
+
+	os.Remove(tmp)
+	err := os.Link(r.filepath, tmp)
 	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, prev)
+}
+
+// Load reads the state file and falls back to the previous generation when
+// it is missing or fails its checksum.
+func (r *FileStore) Load(state *model.AgentState) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	err := r.load(r.filepath, state)
+	if err == nil {
+		r.valid = true
+		return nil
+	}
+
+	if !errors.Is(err, os.ErrNotExist) {
+		r.log.Error("agent state is corrupt, trying previous generation", zap.String("path", r.filepath), zap.Error(err))
+	}
+
+	prevErr := r.load(r.filepath+".prev", state)
+	if prevErr != nil {
 		r.log.Error("failed read file store", zap.Error(err))
 		return err
 	}
 
-	err = json.Unmarshal(data, &state)
+	r.log.Warn("restored previous generation of agent state", zap.String("path", r.filepath+".prev"))
+	return nil
+}
+
+func (r *FileStore) load(path string, state *model.AgentState) error {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		r.log.Error("failed parse json encoded", zap.Error(err))
 		return err
 	}
 
-	return nil
+	var file stateFile
+	err = json.Unmarshal(data, &file)
+	if err != nil {
+		return err
+	}
+
+	// files written before checksums were added hold the bare state.
+	if file.Checksum == "" && file.State == nil {
+		return json.Unmarshal(data, state)
+	}
+
+	if checksum(file.State) != file.Checksum {
+		return errors.New("checksum mismatch")
+	}
+
+	return json.Unmarshal(file.State, state)
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
 }
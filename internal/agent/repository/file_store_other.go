@@ -0,0 +1,15 @@
+//go:build !unix
+
+package repository
+
+import "os"
+
+// lockFile is a no-op where flock is not available, nothing keeps a second
+// agent process off the state file there.
+func lockFile(f *os.File) error {
+	return nil
+}
+
+func unlockFile(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,159 @@
+package repository
+
+import (
+	model "distributed-configuration/pkg/models"
+	"distributed-configuration/pkg/utils"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func newTestStore(t *testing.T, path string) *FileStore {
+	t.Helper()
+
+	store, err := NewFileStore(&utils.Logger{Logger: zap.NewNop()}, path)
+	if err != nil {
+		t.Fatalf("new file store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+// saveStates writes one state per agent id through a store of its own, so
+// the file ends up holding the last one and .prev the one before it.
+func saveStates(t *testing.T, path string, agentIDs ...string) {
+	t.Helper()
+
+	store := newTestStore(t, path)
+	for _, id := range agentIDs {
+		err := store.Save(&model.AgentState{AgentID: id})
+		if err != nil {
+			t.Fatalf("save %s: %v", id, err)
+		}
+	}
+	store.Close()
+}
+
+func loadState(t *testing.T, path string) (*model.AgentState, error) {
+	t.Helper()
+
+	var state model.AgentState
+	err := newTestStore(t, path).Load(&state)
+	return state.Snapshot(), err
+}
+
+func TestFileStoreRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	saveStates(t, path, "agent-1")
+
+	state, err := loadState(t, path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if state.AgentID != "agent-1" {
+		t.Fatalf("agent id = %q, want agent-1", state.AgentID)
+	}
+}
+
+func TestFileStoreFallsBackToPrevious(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt func(data string) string
+	}{
+		{"truncated", func(data string) string { return data[:len(data)/2] }},
+		{"checksum mismatch", func(data string) string { return strings.Replace(data, "agent-2", "agent-9", 1) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "state.json")
+			saveStates(t, path, "agent-1", "agent-2")
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			err = os.WriteFile(path, []byte(tt.corrupt(string(data))), 0644)
+			if err != nil {
+				t.Fatalf("write: %v", err)
+			}
+
+			state, err := loadState(t, path)
+			if err != nil {
+				t.Fatalf("load: %v", err)
+			}
+			if state.AgentID != "agent-1" {
+				t.Fatalf("agent id = %q, want the previous generation agent-1", state.AgentID)
+			}
+		})
+	}
+}
+
+func TestFileStoreKeepsPreviousUntilStateIsIntact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	saveStates(t, path, "agent-1", "agent-2")
+
+	err := os.WriteFile(path, []byte("{garbage"), 0644)
+	if err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	// loading falls back to .prev, the corrupt file must not replace it.
+	store := newTestStore(t, path)
+	var state model.AgentState
+	err = store.Load(&state)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	err = store.Save(&model.AgentState{AgentID: "agent-3"})
+	if err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	prev, err := os.ReadFile(path + ".prev")
+	if err != nil {
+		t.Fatalf("read previous generation: %v", err)
+	}
+	if !strings.Contains(string(prev), "agent-1") {
+		t.Fatalf("previous generation = %s, want agent-1", prev)
+	}
+
+	// only the intact agent-3 state is rotated by the next save.
+	err = store.Save(&model.AgentState{AgentID: "agent-4"})
+	if err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	prev, err = os.ReadFile(path + ".prev")
+	if err != nil {
+		t.Fatalf("read previous generation: %v", err)
+	}
+	if !strings.Contains(string(prev), "agent-3") {
+		t.Fatalf("previous generation = %s, want agent-3", prev)
+	}
+}
+
+func TestFileStoreLoadsLegacyState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	err := os.WriteFile(path, []byte(`{"agent_id":"legacy","etag":"v3"}`), 0644)
+	if err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	state, err := loadState(t, path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if state.AgentID != "legacy" || state.ETag != "v3" {
+		t.Fatalf("state = %q %q, want legacy v3", state.AgentID, state.ETag)
+	}
+}
+
+func TestFileStoreMissing(t *testing.T) {
+	_, err := loadState(t, filepath.Join(t.TempDir(), "state.json"))
+	if !os.IsNotExist(err) {
+		t.Fatalf("err = %v, want not exist", err)
+	}
+}
@@ -0,0 +1,25 @@
+//go:build unix
+
+package repository
+
+import (
+	"distributed-configuration/pkg/utils"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestFileStoreLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	first := newTestStore(t, path)
+
+	_, err := NewFileStore(&utils.Logger{Logger: zap.NewNop()}, path)
+	if err == nil || !strings.Contains(err.Error(), "used by another agent process") {
+		t.Fatalf("second store: err = %v, want the lock error", err)
+	}
+
+	first.Close()
+	newTestStore(t, path)
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	model "distributed-configuration/pkg/models"
+	"encoding/json"
+
+	"go.uber.org/zap"
+)
+
+func (s *AgentService) Status() model.AgentStatus {
+	return s.state.Status()
+}
+
+// Config returns the cached config and its etag.
+func (s *AgentService) Config() (string, json.RawMessage) {
+	state := s.state.Snapshot()
+	return state.ETag, state.Config
+}
+
+// Resync interrupts the running long-poll and fetches the config again
+// without an etag, so the controller answers right away.
+func (s *AgentService) Resync() {
+	s.log.Info("resync requested")
+	s.interruptPoll(true)
+}
+
+// Reregister drops the agent id and registers with the controller again
+// before the next poll.
+func (s *AgentService) Reregister() {
+	s.log.Info("re-register requested")
+	s.state.ResetRegistration()
+	s.persist()
+	s.interruptPoll(false)
+}
+
+// Push delivers the cached config to every sink again and returns the
+// outcome per sink.
+func (s *AgentService) Push(ctx context.Context) map[string]model.Delivery {
+	s.log.Info("push requested", zap.Int("sinks", len(s.sinkNames)))
+	s.deliver(ctx, s.sinkNames)
+
+	res := make(map[string]model.Delivery, len(s.sinkNames))
+	for _, name := range s.sinkNames {
+		res[name] = s.state.Delivery(name)
+	}
+	return res
+}
+
+func (s *AgentService) startPoll(ctx context.Context) (context.Context, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pollCtx, cancel := context.WithCancel(ctx)
+	s.pollCancel = cancel
+
+	force := s.resync
+	s.resync = false
+	return pollCtx, force
+}
+
+// stopPoll releases the long-poll context and reports whether a control
+// action interrupted it.
+func (s *AgentService) stopPoll() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.pollCancel != nil {
+		s.pollCancel()
+		s.pollCancel = nil
+	}
+
+	interrupted := s.interrupted
+	s.interrupted = false
+	return interrupted
+}
+
+func (s *AgentService) interruptPoll(resync bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if resync {
+		s.resync = true
+	}
+	if s.pollCancel != nil {
+		s.pollCancel()
+		s.interrupted = true
+	}
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	retryBaseDelay = 1 * time.Second
+	retryMaxDelay  = 5 * time.Minute
+	retryIdleCheck = 1 * time.Minute
+)
+
+// deliver sends the current config in parallel to the named sinks. A
+// failed sink stays pending and is retried by retryDeliveries with
+// exponential backoff, the schedule is persisted with the agent state so
+// it survives restarts.
+//
+// Polling, retries and the control API all deliver, so every sink takes
+// its lock first and then sends the config current at that point. A push
+// that waited behind a newer one is dropped, an older ETag never lands
+// after a newer one.
+func (s *AgentService) deliver(ctx context.Context, names []string) {
+	started := s.state.Snapshot()
+	if started.Config == nil || len(names) == 0 {
+		return
+	}
+
+	var (
+		wg     sync.WaitGroup
+		failed bool
+		mu     sync.Mutex
+	)
+	for _, name := range names {
+		sk, ok := s.sinks[name]
+		if !ok {
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			lock := s.sinkLocks[name]
+			lock.Lock()
+			defer lock.Unlock()
+
+			state := s.state.Snapshot()
+			if state.ETag != started.ETag && s.state.Delivery(name).ETag == state.ETag {
+				// a newer config reached the sink while this push waited.
+				return
+			}
+
+			attempts := s.state.Delivery(name).Attempts
+			err := sk.Deliver(ctx, state.ETag, state.Config)
+			if err != nil {
+				next := time.Now().Add(retryBackoff(attempts + 1))
+				s.state.RecordDelivery(name, state.ETag, err, next)
+				s.log.Error(
+					"failed deliver config",
+					zap.String("sink", name),
+					zap.Int("attempts", attempts+1),
+					zap.Time("next_retry", next),
+					zap.Error(err),
+				)
+
+				mu.Lock()
+				failed = true
+				mu.Unlock()
+				return
+			}
+
+			s.state.RecordDelivery(name, state.ETag, nil, time.Time{})
+			s.log.Info("delivered config", zap.String("sink", name), zap.String("etag", state.ETag))
+		}()
+	}
+	wg.Wait()
+
+	s.persist()
+
+	if failed {
+		select {
+		case s.retryCh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// retryDeliveries re-sends the current config to pending sinks on pushCtx
+// as their retry time comes due, until ctx is cancelled.
+func (s *AgentService) retryDeliveries(ctx, pushCtx context.Context) {
+	for {
+		wait := retryIdleCheck
+		if next, ok := s.state.NextRetry(s.sinkNames); ok {
+			wait = max(time.Until(next), 0)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-s.retryCh:
+			timer.Stop()
+			continue
+		case <-timer.C:
+		}
+
+		s.deliver(pushCtx, s.state.DueSinks(s.sinkNames, time.Now()))
+	}
+}
+
+// retryBackoff doubles the delay per attempt up to retryMaxDelay and picks
+// a random point in its upper half so sinks recovering together are not
+// hit at once.
+func retryBackoff(attempts int) time.Duration {
+	delay := retryMaxDelay
+	if attempts < 20 {
+		delay = min(retryBaseDelay<<(attempts-1), retryMaxDelay)
+	}
+
+	return delay/2 + rand.N(delay/2+1)
+}
@@ -4,10 +4,14 @@ import (
 	"context"
 	"distributed-configuration/internal/agent/client"
 	"distributed-configuration/internal/agent/config"
+	"distributed-configuration/internal/agent/hook"
 	"distributed-configuration/internal/agent/repository"
+	"distributed-configuration/internal/agent/sink"
 	model "distributed-configuration/pkg/models"
 	"distributed-configuration/pkg/utils"
+	"errors"
 	"os"
+	"sync"
 	"time"
 
 	"go.uber.org/zap"
@@ -18,53 +22,116 @@ type AgentService struct {
 	repo       *repository.FileStore
 	log        *utils.Logger
 	controller client.ControllerClient
-	worker     client.WorkerClient
+	sinks      map[string]sink.Sink
+	sinkNames  []string
+	sinkLocks  map[string]*sync.Mutex
+	hooks      []hook.Hook
 	cfg        *config.Config
+	retryCh    chan struct{}
+
+	// pollCancel interrupts the running long-poll for the control actions.
+	mu          sync.Mutex
+	pollCancel  context.CancelFunc
+	interrupted bool
+	resync      bool
 }
 
 func NewAgentService(
 	controller client.ControllerClient,
-	worker client.WorkerClient,
+	sinks []sink.Sink,
+	hooks []hook.Hook,
 	repo *repository.FileStore,
 	log *utils.Logger,
 	cfg *config.Config,
 ) *AgentService {
-	return &AgentService{
+	s := &AgentService{
 		state:      &model.AgentState{},
 		repo:       repo,
 		controller: controller,
 		log:        log,
-		worker:     worker,
+		sinks:      make(map[string]sink.Sink, len(sinks)),
+		sinkNames:  make([]string, 0, len(sinks)),
+		sinkLocks:  make(map[string]*sync.Mutex, len(sinks)),
+		hooks:      hooks,
 		cfg:        cfg,
+		retryCh:    make(chan struct{}, 1),
+	}
+	for _, sk := range sinks {
+		s.sinks[sk.Name()] = sk
+		s.sinkNames = append(s.sinkNames, sk.Name())
+		s.sinkLocks[sk.Name()] = &sync.Mutex{}
 	}
-}
 
-func (s *AgentService) Start(ctx context.Context) {
+	// the state is restored up front so the status API never sees it
+	// being swapped.
 	var state model.AgentState
-
-	err := s.repo.Load(&state)
+	err := repo.Load(&state)
 	if err == nil {
-		s.log.Info("restore state value")
+		log.Info("restore state value")
 		s.state = &state
+		s.controller.UseEndpoint(s.state.GetController())
+	}
 
-		if s.state.Config != nil {
-			err = s.worker.PushConfig(ctx, s.state.Config)
-			if err != nil {
-				s.log.Error("failed push update to worker", zap.Error(err))
-			}
-		}
+	return s
+}
+
+// Start runs the agent until ctx is cancelled. In-flight deliveries and
+// reload hooks then get up to SHUTDOWN_TIMEOUT to finish before they are
+// aborted, and the state is flushed before Start returns.
+func (s *AgentService) Start(ctx context.Context) {
+	// deliveries run on their own context so a shutdown lets a push that
+	// already started finish instead of cutting it off.
+	pushCtx, cancelPush := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelPush()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+
+		// workers keep config in memory only, so push again after restart.
+		s.deliver(pushCtx, s.sinkNames)
+		s.polling(ctx, pushCtx)
+	}()
+	go func() {
+		defer wg.Done()
+		s.retryDeliveries(ctx, pushCtx)
+	}()
+
+	<-ctx.Done()
+	s.log.Info("shutting down agent", zap.Duration("timeout", s.cfg.ShutdownTimeout))
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(s.cfg.ShutdownTimeout):
+		s.log.Warn("shutdown timeout reached, aborting in-flight deliveries")
+		cancelPush()
+		<-done
 	}
 
-	if s.state.AgentID == "" {
-		s.register(ctx)
+	if s.cfg.Deregister {
+		s.deregister()
 	}
 
-	s.polling(ctx)
+	err := s.persist()
+	if err != nil {
+		return
+	}
+	s.log.Info("agent stopped")
 }
 
 func (s *AgentService) register(ctx context.Context) {
 	backoff := 1 * time.Second
-	for s.state.AgentID == "" {
+	for {
+		if agentID, _, _ := s.state.Get(); agentID != "" {
+			return
+		}
 		s.log.Info("attempting to register")
 
 		hostname, err := os.Hostname()
@@ -72,10 +139,15 @@ func (s *AgentService) register(ctx context.Context) {
 			hostname = "unknown"
 		}
 
-		res, err := s.controller.Register(ctx, s.cfg.AgentName, hostname)
+		// the applied etag lets the controller record what this agent
+		// already holds when it registers again.
+		_, etag, _ := s.state.Get()
+		res, err := s.controller.Register(ctx, s.cfg.AgentName, hostname, etag)
 		if err == nil {
 			s.state.RegistraionData(res.AgentId, res.PollUrl, res.PollIntervalSeconds)
-			s.repo.Save(s.state.Snapshot())
+			// on a failed write the agent keeps running, but registers
+			// again after a restart.
+			s.persist()
 			s.log.Info(
 				"registered agent",
 				zap.String("agent_id", res.AgentId),
@@ -86,7 +158,11 @@ func (s *AgentService) register(ctx context.Context) {
 		}
 
 		s.log.Warn("failed register agent", zap.Error(err))
-		time.Sleep(backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
 
 		backoff *= 2
 		if backoff > 30*time.Second {
@@ -95,7 +171,30 @@ func (s *AgentService) register(ctx context.Context) {
 	}
 }
 
-func (s *AgentService) polling(ctx context.Context) {
+// deregister removes the agent from the controller on shutdown and forgets
+// its id, so the next start registers afresh.
+func (s *AgentService) deregister() {
+	agentID, _, _ := s.state.Get()
+	if agentID == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := s.controller.Deregister(ctx, agentID)
+	if err != nil {
+		s.log.Warn("failed deregister agent", zap.String("agent_id", agentID), zap.Error(err))
+		return
+	}
+
+	s.state.ResetRegistration()
+	s.log.Info("deregistered agent", zap.String("agent_id", agentID))
+}
+
+// polling long-polls the controller until ctx is cancelled, new configs are
+// delivered and their hooks run on pushCtx.
+func (s *AgentService) polling(ctx, pushCtx context.Context) {
 	backoff := 1 * time.Second
 
 	for {
@@ -103,9 +202,43 @@ func (s *AgentService) polling(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		default:
-			agenID, etag, pollUrl := s.state.Get()
-			res, err := s.controller.FetchConfig(ctx, agenID, etag, pollUrl)
+			if agentID, _, _ := s.state.Get(); agentID == "" {
+				s.register(ctx)
+				continue
+			}
+
+			pollCtx, force := s.startPoll(ctx)
+			agenID, _, pollUrl := s.state.Get()
+			etag := s.state.PollETag()
+			if force {
+				etag = ""
+			}
+			endpoint := s.controller.Endpoint()
+			res, err := s.controller.FetchConfig(pollCtx, agenID, etag, pollUrl)
+			interrupted := s.stopPoll()
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil && interrupted {
+				// interrupted by a control action.
+				continue
+			}
+			s.state.RecordPoll(err)
+			if errors.Is(err, utils.ErrUnknownAgent) {
+				s.log.Warn("controller does not know this agent, registering again", zap.String("agent_id", agenID))
+				s.state.ResetRegistration()
+				s.persist()
+				backoff = 1 * time.Second
+				continue
+			}
 			if err != nil {
+				// the client failed over to another controller, retry there
+				// right away with the same agent id and etag.
+				if s.controller.Endpoint() != endpoint {
+					backoff = 1 * time.Second
+					continue
+				}
+
 				s.log.Error("poll failed", zap.Error(err), zap.Int("backoff", int(backoff)))
 				select {
 				case <-time.After(backoff):
@@ -120,6 +253,11 @@ func (s *AgentService) polling(ctx context.Context) {
 
 			backoff = 1 * time.Second
 
+			if endpoint != s.state.GetController() {
+				s.state.SetController(endpoint)
+				s.persist()
+			}
+
 			if res.Data == nil {
 				s.log.Warn("data not modified")
 				select {
@@ -130,14 +268,73 @@ func (s *AgentService) polling(ctx context.Context) {
 				}
 			}
 
+			// the next poll waits for a version after the rejected one.
+			if s.state.IsRejected(res.ETag) {
+				s.log.Warn("skip rejected config", zap.String("etag", res.ETag))
+				continue
+			}
+
+			if force && res.ETag == s.state.Snapshot().ETag {
+				s.log.Info("resync found config up to date", zap.String("etag", res.ETag))
+				continue
+			}
+
 			s.log.Info("received new config update", zap.String("etag", res.ETag))
 
-			s.state.UpdateConfig(res.ETag, res.Data)
-			s.repo.Save(s.state.Snapshot())
-			err = s.worker.PushConfig(ctx, s.state.Config)
-			if err != nil {
-				s.log.Error("failed push update to worker", zap.Error(err))
+			prev := s.state.Snapshot()
+			s.apply(pushCtx, res.ETag, res.Data)
+
+			if !s.runHooks(pushCtx, res.ETag) && s.cfg.HookRevert && prev.Config != nil {
+				s.log.Warn(
+					"reload hook failed, reverting config",
+					zap.String("rejected", res.ETag),
+					zap.String("etag", prev.ETag),
+				)
+				s.state.Reject(res.ETag)
+				s.apply(pushCtx, prev.ETag, prev.Config)
+				s.runHooks(pushCtx, prev.ETag)
 			}
 		}
 	}
 }
+
+// persist writes the agent state to disk. A failed write is logged, the
+// agent keeps running on the state in memory.
+func (s *AgentService) persist() error {
+	err := s.repo.Save(s.state.Snapshot())
+	if err != nil {
+		s.log.Error("failed persist agent state", zap.Error(err))
+	}
+	return err
+}
+
+func (s *AgentService) apply(ctx context.Context, etag string, config []byte) {
+	s.state.UpdateConfig(etag, config)
+	s.persist()
+	s.deliver(ctx, s.state.PendingSinks(s.sinkNames))
+}
+
+// runHooks notifies the consuming processes of a new config and reports
+// whether every hook succeeded.
+func (s *AgentService) runHooks(ctx context.Context, etag string) bool {
+	if len(s.hooks) == 0 {
+		return true
+	}
+
+	ok := true
+	results := make([]model.HookResult, 0, len(s.hooks))
+	for _, h := range s.hooks {
+		res := h.Run(ctx, etag)
+		results = append(results, res)
+		if !res.Success {
+			ok = false
+			continue
+		}
+		s.log.Info("reload hook succeeded", zap.String("hook", res.Name), zap.String("etag", etag))
+	}
+
+	s.state.RecordHooks(results)
+	s.persist()
+
+	return ok
+}
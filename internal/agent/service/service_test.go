@@ -0,0 +1,326 @@
+package service
+
+import (
+	"context"
+	"distributed-configuration/internal/agent/config"
+	"distributed-configuration/internal/agent/hook"
+	"distributed-configuration/internal/agent/repository"
+	"distributed-configuration/internal/agent/sink"
+	model "distributed-configuration/pkg/models"
+	"distributed-configuration/pkg/utils"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// fakeController registers the agent, serves one config and then blocks the
+// long-poll until it is cancelled.
+type fakeController struct {
+	mu           sync.Mutex
+	fetches      int
+	deregistered []string
+}
+
+func (c *fakeController) Register(ctx context.Context, agentName, hostname, etag string) (model.AgentResponse, error) {
+	return model.AgentResponse{AgentId: "agent-1", PollUrl: "/config", PollIntervalSeconds: 1}, nil
+}
+
+func (c *fakeController) FetchConfig(ctx context.Context, agentID, etag, pollUrl string) (model.ConfigResponse, error) {
+	c.mu.Lock()
+	c.fetches++
+	first := c.fetches == 1
+	c.mu.Unlock()
+
+	if first {
+		return model.ConfigResponse{ETag: "v1", Data: json.RawMessage(`{"a":1}`)}, nil
+	}
+	<-ctx.Done()
+	return model.ConfigResponse{}, ctx.Err()
+}
+
+func (c *fakeController) Deregister(ctx context.Context, agentID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deregistered = append(c.deregistered, agentID)
+	return nil
+}
+
+func (c *fakeController) Endpoint() string {
+	return "http://controller"
+}
+
+func (c *fakeController) UseEndpoint(url string) {}
+
+// blockingSink holds every delivery until release is closed or its context
+// is cancelled.
+type blockingSink struct {
+	started chan struct{}
+	release chan struct{}
+
+	mu     sync.Mutex
+	result error
+	done   bool
+}
+
+func newBlockingSink() *blockingSink {
+	return &blockingSink{started: make(chan struct{}, 1), release: make(chan struct{})}
+}
+
+func (s *blockingSink) Name() string {
+	return "blocking"
+}
+
+func (s *blockingSink) Deliver(ctx context.Context, etag string, config json.RawMessage) error {
+	select {
+	case s.started <- struct{}{}:
+	default:
+	}
+
+	var err error
+	select {
+	case <-s.release:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	s.mu.Lock()
+	s.result, s.done = err, true
+	s.mu.Unlock()
+	return err
+}
+
+func (s *blockingSink) outcome() (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.done, s.result
+}
+
+type shutdownTest struct {
+	t          *testing.T
+	path       string
+	controller *fakeController
+	sink       *blockingSink
+	repo       *repository.FileStore
+	svc        *AgentService
+}
+
+func newShutdownTest(t *testing.T, cfg *config.Config) *shutdownTest {
+	t.Helper()
+
+	log := &utils.Logger{Logger: zap.NewNop()}
+	path := filepath.Join(t.TempDir(), "state.json")
+	repo, err := repository.NewFileStore(log, path)
+	if err != nil {
+		t.Fatalf("open state: %v", err)
+	}
+
+	st := &shutdownTest{
+		t:          t,
+		path:       path,
+		controller: &fakeController{},
+		sink:       newBlockingSink(),
+		repo:       repo,
+	}
+	st.svc = NewAgentService(st.controller, []sink.Sink{st.sink}, nil, repo, log, cfg)
+	return st
+}
+
+// run starts the agent, waits until the sink received the config and then
+// cancels the agent context. The returned channel is closed when Start
+// returned.
+func (st *shutdownTest) run() chan struct{} {
+	ctx, cancel := context.WithCancel(context.Background())
+	st.t.Cleanup(cancel)
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		st.svc.Start(ctx)
+	}()
+
+	select {
+	case <-st.sink.started:
+	case <-time.After(5 * time.Second):
+		st.t.Fatal("config was never delivered")
+	}
+	cancel()
+
+	return stopped
+}
+
+func (st *shutdownTest) wait(stopped chan struct{}, timeout time.Duration) {
+	st.t.Helper()
+
+	select {
+	case <-stopped:
+	case <-time.After(timeout):
+		st.t.Fatal("agent did not stop")
+	}
+}
+
+// saved reads the flushed state back from disk.
+func (st *shutdownTest) saved() *model.AgentState {
+	st.t.Helper()
+
+	err := st.repo.Close()
+	if err != nil {
+		st.t.Fatalf("close state: %v", err)
+	}
+	repo, err := repository.NewFileStore(&utils.Logger{Logger: zap.NewNop()}, st.path)
+	if err != nil {
+		st.t.Fatalf("reopen state: %v", err)
+	}
+	defer repo.Close()
+
+	var state model.AgentState
+	err = repo.Load(&state)
+	if err != nil {
+		st.t.Fatalf("load state: %v", err)
+	}
+	return state.Snapshot()
+}
+
+func TestStartDrainsInFlightDelivery(t *testing.T) {
+	st := newShutdownTest(t, &config.Config{ShutdownTimeout: 5 * time.Second})
+	stopped := st.run()
+
+	select {
+	case <-stopped:
+		t.Fatal("agent stopped before the in-flight delivery finished")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(st.sink.release)
+	st.wait(stopped, 5*time.Second)
+
+	done, err := st.sink.outcome()
+	if !done || err != nil {
+		t.Fatalf("delivery done = %v, err = %v, want finished", done, err)
+	}
+
+	state := st.saved()
+	if state.ETag != "v1" {
+		t.Fatalf("saved etag = %q, want v1", state.ETag)
+	}
+	delivery := state.Deliveries[st.sink.Name()]
+	if delivery.ETag != "v1" || delivery.LastError != "" {
+		t.Fatalf("saved delivery = %+v, want v1 without error", delivery)
+	}
+	if len(st.controller.deregistered) != 0 {
+		t.Fatalf("deregistered %v without DEREGISTER_ON_SHUTDOWN", st.controller.deregistered)
+	}
+	if state.AgentID != "agent-1" {
+		t.Fatalf("saved agent id = %q, want agent-1", state.AgentID)
+	}
+}
+
+func TestStartAbortsDeliveryAfterTimeout(t *testing.T) {
+	st := newShutdownTest(t, &config.Config{ShutdownTimeout: 100 * time.Millisecond})
+	stopped := st.run()
+	st.wait(stopped, 5*time.Second)
+
+	done, err := st.sink.outcome()
+	if !done || !errors.Is(err, context.Canceled) {
+		t.Fatalf("delivery done = %v, err = %v, want aborted", done, err)
+	}
+
+	state := st.saved()
+	if state.ETag != "v1" {
+		t.Fatalf("saved etag = %q, want v1", state.ETag)
+	}
+	delivery := state.Deliveries[st.sink.Name()]
+	if delivery.LastError == "" || delivery.NextRetry.IsZero() {
+		t.Fatalf("saved delivery = %+v, want a pending retry", delivery)
+	}
+}
+
+func TestStartDeregisters(t *testing.T) {
+	st := newShutdownTest(t, &config.Config{ShutdownTimeout: 5 * time.Second, Deregister: true})
+	close(st.sink.release)
+	stopped := st.run()
+	st.wait(stopped, 5*time.Second)
+
+	if len(st.controller.deregistered) != 1 || st.controller.deregistered[0] != "agent-1" {
+		t.Fatalf("deregistered = %v, want [agent-1]", st.controller.deregistered)
+	}
+	state := st.saved()
+	if state.AgentID != "" {
+		t.Fatalf("saved agent id = %q, want it cleared", state.AgentID)
+	}
+}
+
+// revertController serves v1 and then v2, and records the If-None-Match etag
+// of every poll after that.
+type revertController struct {
+	fakeController
+
+	polled chan string
+}
+
+func (c *revertController) FetchConfig(ctx context.Context, agentID, etag, pollUrl string) (model.ConfigResponse, error) {
+	switch etag {
+	case "":
+		return model.ConfigResponse{ETag: "v1", Data: json.RawMessage(`{"a":1}`)}, nil
+	case "v1":
+		return model.ConfigResponse{ETag: "v2", Data: json.RawMessage(`{"a":2}`)}, nil
+	}
+
+	c.polled <- etag
+	<-ctx.Done()
+	return model.ConfigResponse{}, ctx.Err()
+}
+
+// failingHook fails the reload of one version.
+type failingHook struct {
+	etag string
+}
+
+func (h failingHook) Name() string {
+	return "failing"
+}
+
+func (h failingHook) Run(ctx context.Context, etag string) model.HookResult {
+	return model.HookResult{Name: h.Name(), ETag: etag, Success: etag != h.etag}
+}
+
+func TestRevertPollsAfterRejectedVersion(t *testing.T) {
+	log := &utils.Logger{Logger: zap.NewNop()}
+	repo, err := repository.NewFileStore(log, filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatalf("open state: %v", err)
+	}
+	defer repo.Close()
+
+	controller := &revertController{polled: make(chan string, 1)}
+	cfg := &config.Config{ShutdownTimeout: time.Second, HookRevert: true}
+	svc := NewAgentService(controller, nil, []hook.Hook{failingHook{etag: "v2"}}, repo, log, cfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		svc.Start(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-stopped
+	}()
+
+	select {
+	case etag := <-controller.polled:
+		if etag != "v2" {
+			t.Fatalf("polled with etag %q after the revert, want the rejected v2", etag)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("agent did not poll after the revert")
+	}
+
+	state := svc.state.Snapshot()
+	if state.ETag != "v1" || state.RejectedETag != "v2" {
+		t.Fatalf("state etag = %q, rejected = %q, want v1 and v2", state.ETag, state.RejectedETag)
+	}
+}
@@ -0,0 +1,81 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"distributed-configuration/pkg/utils"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type execSink struct {
+	log     *utils.Logger
+	command string
+	args    []string
+	timeout time.Duration
+}
+
+// NewExecSink runs a command with the configuration on stdin and the ETag
+// in CONFIG_ETAG. Arguments come from repeated arg query parameters.
+func NewExecSink(log *utils.Logger, u *url.URL) (Sink, error) {
+	if u.Path == "" {
+		return nil, fmt.Errorf("missing command")
+	}
+
+	timeout := 30 * time.Second
+	if t := u.Query().Get("timeout"); t != "" {
+		d, err := time.ParseDuration(t)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout: %w", err)
+		}
+		timeout = d
+	}
+
+	return &execSink{
+		log:     log,
+		command: u.Path,
+		args:    u.Query()["arg"],
+		timeout: timeout,
+	}, nil
+}
+
+// Name includes the arguments, the same command run with different
+// arguments is a different sink.
+func (s *execSink) Name() string {
+	name := "exec://" + s.command
+	if len(s.args) > 0 {
+		name += "?" + url.Values{"arg": s.args}.Encode()
+	}
+	return name
+}
+
+func (s *execSink) Deliver(ctx context.Context, etag string, config json.RawMessage) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, s.command, s.args...)
+	cmd.Stdin = bytes.NewReader(config)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	cmd.Env = append(os.Environ(), "CONFIG_ETAG="+etag)
+
+	err := cmd.Run()
+	if err != nil {
+		s.log.Error(
+			"config command failed",
+			zap.String("command", s.command),
+			zap.String("output", output.String()),
+			zap.Error(err),
+		)
+		return fmt.Errorf("%s: %w: %s", s.command, err, output.String())
+	}
+
+	return nil
+}
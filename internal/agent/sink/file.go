@@ -0,0 +1,77 @@
+package sink
+
+import (
+	"context"
+	"distributed-configuration/pkg/utils"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+type fileSink struct {
+	log    *utils.Logger
+	path   string
+	format string
+	mode   os.FileMode
+}
+
+// NewFileSink writes configuration to a local file. The format comes from
+// the format query parameter or the file extension.
+func NewFileSink(log *utils.Logger, u *url.URL) (Sink, error) {
+	if u.Path == "" {
+		return nil, fmt.Errorf("missing file path")
+	}
+
+	format := u.Query().Get("format")
+	if format == "" {
+		format = formatFromExt(u.Path)
+	}
+	if _, err := utils.Encode(format, json.RawMessage("{}")); err != nil {
+		return nil, err
+	}
+
+	return &fileSink{
+		log:    log,
+		path:   u.Path,
+		format: format,
+		mode:   0644,
+	}, nil
+}
+
+func formatFromExt(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return utils.FormatYAML
+	case ".toml":
+		return utils.FormatTOML
+	case ".env":
+		return utils.FormatEnv
+	default:
+		return utils.FormatJSON
+	}
+}
+
+func (s *fileSink) Name() string {
+	return "file://" + s.path
+}
+
+func (s *fileSink) Deliver(ctx context.Context, etag string, config json.RawMessage) error {
+	data, err := utils.Encode(s.format, config)
+	if err != nil {
+		s.log.Error("failed encode config", zap.String("format", s.format), zap.Error(err))
+		return err
+	}
+
+	err = utils.WriteFileAtomic(s.path, data, s.mode)
+	if err != nil {
+		s.log.Error("failed write config file", zap.String("path", s.path), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,25 @@
+package sink
+
+import (
+	"context"
+	"distributed-configuration/internal/agent/client"
+	"encoding/json"
+)
+
+type httpSink struct {
+	worker client.WorkerClient
+	url    string
+}
+
+// NewHTTPSink pushes configuration to a worker over HTTP.
+func NewHTTPSink(worker client.WorkerClient, url string) Sink {
+	return &httpSink{worker: worker, url: url}
+}
+
+func (s *httpSink) Name() string {
+	return s.url
+}
+
+func (s *httpSink) Deliver(ctx context.Context, etag string, config json.RawMessage) error {
+	return s.worker.PushConfig(ctx, s.url, etag, config)
+}
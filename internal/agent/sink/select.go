@@ -0,0 +1,37 @@
+package sink
+
+import (
+	"context"
+	"distributed-configuration/pkg/utils"
+	"encoding/json"
+)
+
+type selectSink struct {
+	Sink
+	pointers []string
+}
+
+// NewSelectSink narrows the configuration to the JSON Pointers before
+// handing it to next, so a sink only sees the sections it needs.
+func NewSelectSink(next Sink, pointers []string) (Sink, error) {
+	for _, pointer := range pointers {
+		_, err := utils.ParsePointer(pointer)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &selectSink{
+		Sink:     next,
+		pointers: pointers,
+	}, nil
+}
+
+func (s *selectSink) Deliver(ctx context.Context, etag string, config json.RawMessage) error {
+	subtree, err := utils.SelectJSON(config, s.pointers)
+	if err != nil {
+		return err
+	}
+
+	return s.Sink.Deliver(ctx, etag, subtree)
+}
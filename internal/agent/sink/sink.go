@@ -0,0 +1,95 @@
+package sink
+
+import (
+	"context"
+	"distributed-configuration/internal/agent/client"
+	"distributed-configuration/internal/agent/config"
+	"distributed-configuration/pkg/utils"
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// Sink is a destination the agent delivers configuration to.
+type Sink interface {
+	// Name identifies the sink in logs and in the agent state.
+	Name() string
+	Deliver(ctx context.Context, etag string, config json.RawMessage) error
+}
+
+// New builds the sinks from WORKER_URLS and SINKS. Every SINKS entry is a
+// URL whose scheme selects the sink:
+//
+//	http://host:8181/agent-config                      push to a worker
+//	file:///etc/app/config.yaml?format=yaml            write a local file (json, yaml, toml, env)
+//	unix:///run/app/config.sock                        write to a unix domain socket
+//	exec:///usr/local/bin/apply?timeout=10s            run a command with the config on stdin
+//	template:///etc/agent/app.tmpl?dest=/etc/app.conf  render a text/template into dest
+//
+// Any sink takes select=<json pointer>, repeatable, to receive only those
+// parts of the configuration, e.g. file:///etc/app/db.json?select=/database.
+//
+// Sinks are tracked by name in the agent state, so two entries naming the
+// same destination are rejected.
+func New(log *utils.Logger, cfg *config.Config, worker client.WorkerClient) ([]Sink, error) {
+	sinks := make([]Sink, 0, len(cfg.WorkerUrls)+len(cfg.Sinks))
+	names := make(map[string]bool, cap(sinks))
+	add := func(s Sink) error {
+		if names[s.Name()] {
+			return fmt.Errorf("duplicate sink %q", s.Name())
+		}
+		names[s.Name()] = true
+		sinks = append(sinks, s)
+		return nil
+	}
+
+	for _, workerUrl := range cfg.WorkerUrls {
+		err := add(NewHTTPSink(worker, workerUrl))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, spec := range cfg.Sinks {
+		u, err := url.Parse(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sink %q: %w", spec, err)
+		}
+
+		query := u.Query()
+		pointers := query["select"]
+		if len(pointers) > 0 {
+			query.Del("select")
+			u.RawQuery = query.Encode()
+			spec = u.String()
+		}
+
+		var s Sink
+		switch u.Scheme {
+		case "http", "https":
+			s = NewHTTPSink(worker, spec)
+		case "file":
+			s, err = NewFileSink(log, u)
+		case "unix":
+			s, err = NewSocketSink(log, u, cfg.Timeout)
+		case "exec":
+			s, err = NewExecSink(log, u)
+		case "template":
+			s, err = NewTemplateSink(log, u)
+		default:
+			err = fmt.Errorf("unknown scheme %q", u.Scheme)
+		}
+		if err == nil && len(pointers) > 0 {
+			s, err = NewSelectSink(s, pointers)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid sink %q: %w", spec, err)
+		}
+		err = add(s)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return sinks, nil
+}
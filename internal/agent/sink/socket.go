@@ -0,0 +1,60 @@
+package sink
+
+import (
+	"context"
+	"distributed-configuration/pkg/utils"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type socketSink struct {
+	log     *utils.Logger
+	path    string
+	timeout time.Duration
+}
+
+// NewSocketSink writes the configuration as one JSON line to a unix domain
+// socket, the listener reads until the connection is closed.
+func NewSocketSink(log *utils.Logger, u *url.URL, timeout time.Duration) (Sink, error) {
+	if u.Path == "" {
+		return nil, fmt.Errorf("missing socket path")
+	}
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+
+	return &socketSink{log: log, path: u.Path, timeout: timeout}, nil
+}
+
+func (s *socketSink) Name() string {
+	return "unix://" + s.path
+}
+
+func (s *socketSink) Deliver(ctx context.Context, etag string, config json.RawMessage) error {
+	var d net.Dialer
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	conn, err := d.DialContext(ctx, "unix", s.path)
+	if err != nil {
+		s.log.Error("failed connect config socket", zap.String("path", s.path), zap.Error(err))
+		return err
+	}
+	defer conn.Close()
+
+	deadline, _ := ctx.Deadline()
+	conn.SetWriteDeadline(deadline)
+
+	_, err = conn.Write(append(append([]byte{}, config...), '\n'))
+	if err != nil {
+		s.log.Error("failed write config socket", zap.String("path", s.path), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
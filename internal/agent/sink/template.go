@@ -0,0 +1,152 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"distributed-configuration/pkg/utils"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"text/template"
+
+	"go.uber.org/zap"
+)
+
+type templateSink struct {
+	log      *utils.Logger
+	template string
+	dest     string
+	mode     os.FileMode
+}
+
+// NewTemplateSink renders the configuration through a text/template file
+// into dest, e.g. template:///etc/agent/nginx.tmpl?dest=/etc/nginx/nginx.conf.
+// The destination is only rewritten when the rendered output changes.
+func NewTemplateSink(log *utils.Logger, u *url.URL) (Sink, error) {
+	if u.Path == "" {
+		return nil, fmt.Errorf("missing template path")
+	}
+
+	dest := u.Query().Get("dest")
+	if dest == "" {
+		return nil, fmt.Errorf("missing dest")
+	}
+
+	mode := os.FileMode(0644)
+	if m := u.Query().Get("mode"); m != "" {
+		v, err := strconv.ParseUint(m, 8, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mode: %w", err)
+		}
+		mode = os.FileMode(v)
+	}
+
+	s := &templateSink{
+		log:      log,
+		template: u.Path,
+		dest:     dest,
+		mode:     mode,
+	}
+
+	_, err := s.parse()
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *templateSink) Name() string {
+	return "template://" + s.template + "?dest=" + s.dest
+}
+
+func (s *templateSink) Deliver(ctx context.Context, etag string, config json.RawMessage) error {
+	// parsed on every delivery so template edits apply without a restart.
+	tpl, err := s.parse()
+	if err != nil {
+		s.log.Error("failed parse template", zap.String("template", s.template), zap.Error(err))
+		return err
+	}
+
+	data, err := utils.DecodeJSON(config)
+	if err != nil {
+		s.log.Error("failed decode config", zap.Error(err))
+		return err
+	}
+
+	var out bytes.Buffer
+	err = tpl.Execute(&out, data)
+	if err != nil {
+		s.log.Error("failed render template", zap.String("template", s.template), zap.Error(err))
+		return err
+	}
+
+	current, err := os.ReadFile(s.dest)
+	if err == nil && bytes.Equal(current, out.Bytes()) {
+		s.log.Debug("rendered output unchanged", zap.String("dest", s.dest))
+		return nil
+	}
+
+	err = utils.WriteFileAtomic(s.dest, out.Bytes(), s.mode)
+	if err != nil {
+		s.log.Error("failed write rendered template", zap.String("dest", s.dest), zap.Error(err))
+		return err
+	}
+
+	s.log.Info("rendered template", zap.String("template", s.template), zap.String("dest", s.dest))
+	return nil
+}
+
+func (s *templateSink) parse() (*template.Template, error) {
+	return template.New(filepath.Base(s.template)).Funcs(templateFuncs).ParseFiles(s.template)
+}
+
+var templateFuncs = template.FuncMap{
+	// default returns def when value is missing or empty:
+	// {{ default "localhost" .db.host }}
+	"default": func(def, value any) any {
+		if isEmpty(value) {
+			return def
+		}
+		return value
+	},
+	"env": os.Getenv,
+	"toJSON": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"toPrettyJSON": func(v any) (string, error) {
+		data, err := json.MarshalIndent(v, "", "  ")
+		return string(data), err
+	},
+	"toYAML": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		out, err := utils.Encode(utils.FormatYAML, data)
+		return string(out), err
+	},
+}
+
+func isEmpty(v any) bool {
+	if v == nil {
+		return true
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.String, reflect.Map, reflect.Slice, reflect.Array:
+		return rv.Len() == 0
+	case reflect.Bool:
+		return !rv.Bool()
+	case reflect.Pointer, reflect.Interface:
+		return rv.IsNil()
+	default:
+		return false
+	}
+}
@@ -18,6 +18,14 @@ type Config struct {
 	PollUrl          string        `env:"POLL_URL"`
 	PollInterval     time.Duration `env:"POLL_INTERVAL"`
 	ChannelKey       string        `env:"CHANNEL_KEY"`
+
+	RetentionKeepLast int           `env:"RETENTION_KEEP_LAST"`
+	RetentionKeepDays int           `env:"RETENTION_KEEP_DAYS"`
+	RetentionInterval time.Duration `env:"RETENTION_INTERVAL"`
+
+	ReplicaID string        `env:"REPLICA_ID"`
+	LeaderKey string        `env:"LEADER_KEY"`
+	LeaderTTL time.Duration `env:"LEADER_TTL"`
 }
 
 func NewConfig() (*Config, error) {
@@ -1,68 +1,100 @@
 package handler
 
 import (
+	"bytes"
 	"context"
 	"distributed-configuration/internal/controller/config"
+	"distributed-configuration/internal/controller/repository"
 	"distributed-configuration/internal/controller/service"
 	model "distributed-configuration/pkg/models"
 	"distributed-configuration/pkg/utils"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
 	"time"
 
 	"go.uber.org/zap"
 )
 
 type handler struct {
-	config service.ConfigService
-	agent  service.AgentService
-	cfg    *config.Config
-	log    *utils.Logger
-	notif  *service.RedisNotifier
+	config    service.ConfigService
+	agent     service.AgentService
+	retention service.RetentionService
+	backup    service.BackupService
+	leader    *service.LeaderElector
+	cfg       *config.Config
+	log       *utils.Logger
+	notif     *service.RedisNotifier
 }
 
 func NewHandler(
 	config service.ConfigService,
 	agent service.AgentService,
+	retention service.RetentionService,
+	backup service.BackupService,
+	leader *service.LeaderElector,
 	log *utils.Logger,
 	cfg *config.Config,
 	notif *service.RedisNotifier,
 ) *handler {
 	return &handler{
-		config: config,
-		agent:  agent,
-		log:    log,
-		cfg:    cfg,
-		notif:  notif,
+		config:    config,
+		agent:     agent,
+		retention: retention,
+		backup:    backup,
+		leader:    leader,
+		log:       log,
+		cfg:       cfg,
+		notif:     notif,
 	}
 }
 
 // UpdateConfig godoc
 // @Summary      Update global configuration
-// @Description  Admin endpoint to update the configuration that will be pushed to all agents
+// @Description  Admin endpoint to update the configuration that will be pushed to all agents. YAML and TOML bodies, chosen by Content-Type, are stored as JSON.
 // @Tags         admin
 // @Accept       json
+// @Accept       application/yaml
+// @Accept       application/toml
 // @Produce      json
 // @Security     BearerAuth
-// @Param        config  body      model.Configuration  true  "New Configuration"
-// @Success      200      {object}  map[string]interface{} "message: config updated"
+// @Param        config    body      model.Configuration  true   "New Configuration"
+// @Param        If-Match  header    string               false  "Only save while this is the latest version (ETag)"
+// @Success      201      {object}  map[string]interface{} "message: config updated"
+// @Failure      409      {string}  string  "Another version was saved concurrently"
+// @Failure      412      {string}  string  "If-Match is not the latest version"
 // @Router       /admin/config [post]
 func (h handler) Save(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		h.GetConfig(w, r)
+		return
+	}
 	if r.Method != http.MethodPost {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	var payload model.Configuration
-	err := json.NewDecoder(r.Body).Decode(&payload.Data)
+	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		h.log.Error("invalid request body", zap.Error(err))
+		h.log.Error("failed to read request body", zap.Error(err))
 		http.Error(w, "invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	err = h.config.Save(r.Context(), &payload)
+	// YAML and TOML are normalized to JSON, which is what gets stored.
+	format := utils.FormatFromContentType(r.Header.Get("Content-Type"))
+	data, err := utils.Decode(format, body)
+	if err != nil {
+		h.log.Error("invalid request body", zap.String("format", format), zap.Error(err))
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	payload := model.Configuration{Data: model.JSON(data)}
+
+	config, err := h.config.Save(r.Context(), &payload, r.Header.Get("If-Match"))
 	if err != nil {
 		status, msg := utils.MapError(err)
 		if status == http.StatusNotModified {
@@ -74,18 +106,204 @@ func (h handler) Save(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.notif.PublishUpdate(context.Background())
+	h.publish(config)
+
+	w.Header().Set("ETag", utils.FormatVersion(config.Version))
+	resp := map[string]any{
+		"status":  "success",
+		"message": "configuration saved successfully",
+		"version": config.Version,
+	}
+	utils.WriteJSON(w, http.StatusCreated, resp)
+}
+
+// publish announces a new version to every replica and its waiting agents.
+func (h handler) publish(config model.Configuration) {
+	update := model.ConfigUpdate{
+		Namespace: utils.DefaultNamespace,
+		Version:   config.Version,
+		Hash:      config.Hash(),
+	}
+	err := h.notif.PublishUpdate(context.Background(), update)
 	if err != nil {
 		h.log.Error("failed to publish update", zap.Error(err))
 	}
+}
 
+// GetConfigVersion godoc
+// @Summary      Get a configuration version
+// @Description  Admin endpoint returning the latest configuration, or the given version, with its metadata
+// @Tags         admin
+// @Produce      json
+// @Produce      application/yaml
+// @Produce      application/toml
+// @Security     BearerAuth
+// @Param        version  query     int  false  "Configuration version (default latest)"
+// @Success      200      {object}  model.ConfigVersion
+// @Failure      400      {object}  map[string]string "Invalid version"
+// @Failure      404      {object}  map[string]string "Version not found"
+// @Router       /admin/config [get]
+func (h handler) GetConfig(w http.ResponseWriter, r *http.Request) {
+	version := 0
+	if v := r.URL.Query().Get("version"); v != "" {
+		var err error
+		version, err = strconv.Atoi(v)
+		if err != nil || version < 1 {
+			http.Error(w, "invalid version", http.StatusBadRequest)
+			return
+		}
+	}
+
+	config, err := h.config.Version(r.Context(), version)
+	if err != nil {
+		h.log.Error("failed to get config", zap.Error(err))
+		status, msg := utils.MapError(err)
+		http.Error(w, msg, status)
+		return
+	}
+
+	data, err := json.Marshal(config.Info())
+	if err != nil {
+		h.log.Error("failed to encode config", zap.Error(err))
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("ETag", utils.FormatVersion(config.Version))
+	utils.WriteDocument(w, r, http.StatusOK, data)
+}
+
+// History godoc
+// @Summary      List configuration versions
+// @Description  Admin endpoint listing every stored version without its data, newest first
+// @Tags         admin
+// @Produce      json
+// @Produce      application/yaml
+// @Produce      application/toml
+// @Security     BearerAuth
+// @Success      200      {array}   model.ConfigVersion
+// @Router       /admin/config/history [get]
+func (h handler) History(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	configs, err := h.config.History(r.Context())
+	if err != nil {
+		h.log.Error("failed to list config versions", zap.Error(err))
+		status, msg := utils.MapError(err)
+		http.Error(w, msg, status)
+		return
+	}
+
+	resp := make([]model.ConfigVersion, 0, len(configs))
+	for _, config := range configs {
+		resp = append(resp, config.Info())
+	}
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		h.log.Error("failed to encode config versions", zap.Error(err))
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	utils.WriteDocument(w, r, http.StatusOK, data)
+}
+
+// Rollback godoc
+// @Summary      Roll back to a configuration version
+// @Description  Admin endpoint saving the data of an earlier version as a new version that is pushed to all agents
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param        version  query     int  true  "Configuration version to restore"
+// @Success      201      {object}  map[string]interface{}
+// @Success      304      {string}  string "Already the latest configuration"
+// @Failure      400      {object}  map[string]string "Invalid version"
+// @Failure      404      {object}  map[string]string "Version not found"
+// @Router       /admin/config/rollback [post]
+func (h handler) Rollback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	version, err := strconv.Atoi(r.URL.Query().Get("version"))
+	if err != nil || version < 1 {
+		http.Error(w, "invalid version", http.StatusBadRequest)
+		return
+	}
+
+	config, err := h.config.Rollback(r.Context(), version)
+	if err != nil {
+		status, msg := utils.MapError(err)
+		if status == http.StatusNotModified {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		h.log.Error("failed to roll back config", zap.Error(err))
+		http.Error(w, msg, status)
+		return
+	}
+
+	h.publish(config)
+
+	w.Header().Set("ETag", utils.FormatVersion(config.Version))
 	resp := map[string]any{
 		"status":  "success",
-		"message": "configuration saved successfully",
+		"message": "configuration rolled back successfully",
+		"version": config.Version,
 	}
 	utils.WriteJSON(w, http.StatusCreated, resp)
 }
 
+// Agents godoc
+// @Summary      List or remove agents
+// @Description  Admin endpoint listing registered agents. DELETE removes the agent given by agent_id.
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param        agent_id  query     string  false  "Agent to remove (DELETE only)"
+// @Success      200       {array}   model.Agent
+// @Failure      404       {object}  map[string]string "Agent not found"
+// @Router       /admin/agents [get]
+// @Router       /admin/agents [delete]
+func (h handler) Agents(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		agents, err := h.agent.List(r.Context())
+		if err != nil {
+			h.log.Error("failed to list agents", zap.Error(err))
+			status, msg := utils.MapError(err)
+			http.Error(w, msg, status)
+			return
+		}
+		utils.WriteJSON(w, http.StatusOK, agents)
+	case http.MethodDelete:
+		agentID := r.URL.Query().Get("agent_id")
+		if agentID == "" {
+			http.Error(w, "missing agent id", http.StatusBadRequest)
+			return
+		}
+
+		err := h.agent.Deregister(r.Context(), agentID)
+		if err != nil {
+			status, msg := utils.MapError(err)
+			http.Error(w, msg, status)
+			return
+		}
+
+		resp := map[string]any{
+			"status":   "success",
+			"agent_id": agentID,
+		}
+		utils.WriteJSON(w, http.StatusOK, resp)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
 // RegisterAgent godoc
 // @Summary      Register a new agent
 // @Description  Register an agent to get a unique ID and polling configuration
@@ -98,6 +316,10 @@ func (h handler) Save(w http.ResponseWriter, r *http.Request) {
 // @Failure      400      {object}  map[string]string "Invalid request body"
 // @Router       /register [post]
 func (h handler) Register(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodDelete {
+		h.Deregister(w, r)
+		return
+	}
 	if r.Method != http.MethodPost {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -127,17 +349,55 @@ func (h handler) Register(w http.ResponseWriter, r *http.Request) {
 	utils.WriteJSON(w, http.StatusCreated, resp)
 }
 
+// DeregisterAgent godoc
+// @Summary      Deregister an agent
+// @Description  Remove an agent that is shutting down for good
+// @Tags         agent
+// @Produce      json
+// @Security     BearerAuth
+// @Param        X-Agent-ID  header    string  true  "Unique Agent ID"
+// @Success      200         {object}  map[string]interface{}
+// @Failure      404         {object}  map[string]string "Agent not found"
+// @Router       /register [delete]
+func (h handler) Deregister(w http.ResponseWriter, r *http.Request) {
+	agentID := r.Header.Get("X-Agent-ID")
+	if agentID == "" {
+		http.Error(w, "missing agent id", http.StatusBadRequest)
+		return
+	}
+
+	err := h.agent.Deregister(r.Context(), agentID)
+	if err != nil {
+		status, msg := utils.MapError(err)
+		http.Error(w, msg, status)
+		return
+	}
+
+	resp := map[string]any{
+		"status":   "success",
+		"agent_id": agentID,
+	}
+	utils.WriteJSON(w, http.StatusOK, resp)
+}
+
 // GetConfig godoc
 // @Summary      Poll for latest configuration
 // @Description  Get the latest config if version has changed. Returns 304 if version matches.
+// @Description  With select only the addressed parts of the config are returned and the ETag covers just those parts, so unrelated changes do not wake the request.
 // @Tags         agent
 // @Produce      json
+// @Produce      application/yaml
+// @Produce      application/toml
 // @Security     BearerAuth
-// @Param        X-Agent-ID     header    string  true   "Unique Agent ID"
-// @Param        If-None-Match  header    string  false  "Current config version (ETag)"
+// @Param        X-Agent-ID     header    string    true   "Unique Agent ID"
+// @Param        If-None-Match  header    string    false  "Current config version (ETag)"
+// @Param        select         query     []string  false  "JSON Pointer into the config, e.g. /database (repeatable)"  collectionFormat(multi)
 // @Success      200      		{object}  map[string]interface{}
 // @Success      304            {string}  string "Not Modified"
+// @Failure      400            {object}  map[string]string "Invalid selection"
 // @Failure      401            {object}  map[string]string "Unauthorized"
+// @Failure      404            {string}  string "Unknown agent, it has to register again"
+// @Header       404            {string}  X-Agent-Unknown "true when the agent is not registered"
 // @Router       /config [get]
 func (h handler) Config(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -148,8 +408,27 @@ func (h handler) Config(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	versionx := r.Header.Get("If-None-Match")
 
+	pointers := r.URL.Query()["select"]
+	for _, pointer := range pointers {
+		_, err := utils.ParsePointer(pointer)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
 	sendLatestConfig := func() bool {
-		res, err := h.config.Get(ctx, versionx)
+		var (
+			res  model.Configuration
+			etag string
+			err  error
+		)
+		if len(pointers) > 0 {
+			res, etag, err = h.config.Select(ctx, versionx, pointers)
+		} else {
+			res, err = h.config.Get(ctx, versionx)
+			etag = utils.FormatVersion(res.Version)
+		}
 		if err != nil {
 			status, msg := utils.MapError(err)
 			if status != http.StatusNotModified {
@@ -160,31 +439,199 @@ func (h handler) Config(w http.ResponseWriter, r *http.Request) {
 			return false
 		}
 
-		if fmt.Sprintf("v%d", res.Version) != versionx {
-			resp := map[string]any{}
-			json.Unmarshal(res.Data, &resp)
-			w.Header().Set("ETag", fmt.Sprintf("v%d", res.Version))
-			utils.WriteJSON(w, http.StatusOK, resp)
+		if etag != versionx {
+			w.Header().Set("ETag", etag)
+			utils.WriteDocument(w, r, http.StatusOK, json.RawMessage(res.Data))
 			return true
 		}
 
 		return false
 	}
 
+	// subscribe before the first check so an update landing in between
+	// still wakes this request.
+	updateCh := h.notif.Subscribe(utils.DefaultNamespace, utils.ParseVersion(versionx))
+	defer func() {
+		h.notif.Unsubscribe(utils.DefaultNamespace, updateCh)
+	}()
+
 	if sent := sendLatestConfig(); sent {
 		return
 	}
 
-	updateCh := h.notif.Subscribe()
+	// the periodic check catches versions whose pub/sub message this
+	// replica never received.
+	recheck := time.NewTicker(service.LatestTTL)
+	defer recheck.Stop()
 
-	select {
-	case <-time.After(60 * time.Second):
-		w.WriteHeader(http.StatusNotModified)
+	timeout := time.After(60 * time.Second)
+	for {
+		select {
+		case <-timeout:
+			w.WriteHeader(http.StatusNotModified)
+			return
+		case <-recheck.C:
+			if sent := sendLatestConfig(); sent {
+				return
+			}
+		case update := <-updateCh:
+			// a selection may be untouched by the update, keep waiting for
+			// the next one in that case.
+			updateCh = h.notif.Subscribe(utils.DefaultNamespace, update.Version)
+			if sent := sendLatestConfig(); sent {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// PinConfig godoc
+// @Summary      Pin or unpin a configuration version
+// @Description  Pinned versions are never removed by the retention policy. POST pins, DELETE unpins.
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param        version  query     int  true  "Configuration version"
+// @Success      200      {object}  map[string]interface{}
+// @Failure      400      {object}  map[string]string "Invalid version"
+// @Failure      404      {object}  map[string]string "Version not found"
+// @Router       /admin/config/pin [post]
+// @Router       /admin/config/pin [delete]
+func (h handler) Pin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
-	case <-updateCh:
-		sendLatestConfig()
+	}
+
+	version, err := strconv.Atoi(r.URL.Query().Get("version"))
+	if err != nil || version < 1 {
+		http.Error(w, "invalid version", http.StatusBadRequest)
 		return
-	case <-ctx.Done():
+	}
+
+	pinned := r.Method == http.MethodPost
+	err = h.retention.Pin(r.Context(), version, pinned)
+	if err != nil {
+		h.log.Error("failed to pin config", zap.Error(err))
+		status, msg := utils.MapError(err)
+		http.Error(w, msg, status)
 		return
 	}
+
+	resp := map[string]any{
+		"status":  "success",
+		"version": version,
+		"pinned":  pinned,
+	}
+	utils.WriteJSON(w, http.StatusOK, resp)
+}
+
+// PruneConfig godoc
+// @Summary      Prune configuration versions
+// @Description  Apply the retention policy now. With dry_run=true only lists the versions that would be removed.
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param        dry_run  query     bool  false  "List without deleting"
+// @Success      200      {object}  map[string]interface{}
+// @Router       /admin/config/prune [post]
+func (h handler) Prune(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+	versions, err := h.retention.Prune(r.Context(), dryRun)
+	if err != nil {
+		h.log.Error("failed to prune config", zap.Error(err))
+		status, msg := utils.MapError(err)
+		http.Error(w, msg, status)
+		return
+	}
+
+	resp := map[string]any{
+		"status":   "success",
+		"dry_run":  dryRun,
+		"versions": versions,
+	}
+	utils.WriteJSON(w, http.StatusOK, resp)
+}
+
+// Backup godoc
+// @Summary      Export controller state
+// @Description  Download every configuration version and agent as a gzip compressed JSON archive
+// @Tags         admin
+// @Produce      application/gzip
+// @Security     BearerAuth
+// @Success      200      {file}    file
+// @Router       /admin/backup [get]
+func (h handler) Backup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var buf bytes.Buffer
+	err := h.backup.Export(r.Context(), &buf)
+	if err != nil {
+		h.log.Error("failed to export backup", zap.Error(err))
+		status, msg := utils.MapError(err)
+		http.Error(w, msg, status)
+		return
+	}
+
+	filename := fmt.Sprintf("controller-backup-%s.json.gz", time.Now().UTC().Format("20060102T150405Z"))
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+// Restore godoc
+// @Summary      Restore controller state
+// @Description  Import an archive produced by /admin/backup. conflict decides what happens to versions and agents that already exist.
+// @Tags         admin
+// @Accept       application/gzip
+// @Produce      json
+// @Security     BearerAuth
+// @Param        conflict  query     string  false  "skip (default), overwrite or fail"
+// @Success      200       {object}  model.RestoreResult
+// @Failure      400       {object}  map[string]string "Invalid archive"
+// @Failure      409       {object}  map[string]string "Conflicting rows with conflict=fail"
+// @Router       /admin/restore [post]
+func (h handler) Restore(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	mode := repository.ConflictMode(r.URL.Query().Get("conflict"))
+	res, err := h.backup.Import(r.Context(), r.Body, mode)
+	if err != nil {
+		h.log.Error("failed to restore backup", zap.Error(err))
+		status, msg := utils.MapError(err)
+		http.Error(w, msg, status)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, res)
+}
+
+// Status godoc
+// @Summary      Replica status
+// @Description  Report this controller replica and whether it is the cluster leader
+// @Tags         cluster
+// @Produce      json
+// @Success      200      {object}  model.ReplicaStatus
+// @Router       /status [get]
+func (h handler) Status(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, h.leader.Status())
 }
@@ -25,8 +25,14 @@ func (h handler) Authentication(next http.Handler) http.Handler {
 					return
 				}
 
-				err := h.agent.Verify(ctx, agentID)
+				version := utils.ParseVersion(r.Header.Get("If-None-Match"))
+				err := h.agent.Verify(ctx, agentID, version)
 				if err != nil {
+					// agents tell this apart from other 404s, e.g. of a
+					// proxy, by the header rather than the message.
+					if err == utils.ErrUnknownAgent {
+						w.Header().Set("X-Agent-Unknown", "true")
+					}
 					status, msg := utils.MapError(err)
 					http.Error(w, msg, status)
 					return
@@ -14,6 +14,9 @@ type AgentRepository interface {
 	Create(ctx context.Context, agent *model.Agent) error
 	Get(ctx context.Context, agent *model.Agent) error
 	Update(ctx context.Context, agent *model.Agent) error
+	Delete(ctx context.Context, agentID string) error
+	List(ctx context.Context) ([]model.Agent, error)
+	ConfigVersions(ctx context.Context) ([]int, error)
 }
 
 type agentRepository struct {
@@ -31,6 +34,9 @@ func (r *agentRepository) Create(ctx context.Context, agent *model.Agent) error
 	err := r.db.Create(&agent).Error
 	if err != nil {
 		r.log.Error("failed create new agent", zap.Error(err))
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return utils.ErrConflict
+		}
 		return utils.ErrInternal
 	}
 
@@ -57,3 +63,44 @@ func (r *agentRepository) Update(ctx context.Context, agent *model.Agent) error
 
 	return nil
 }
+
+func (r *agentRepository) Delete(ctx context.Context, agentID string) error {
+	res := r.db.WithContext(ctx).Delete(&model.Agent{}, "id = ?", agentID)
+	if res.Error != nil {
+		r.log.Error("failed delete agent", zap.Error(res.Error))
+		return utils.ErrInternal
+	}
+	if res.RowsAffected == 0 {
+		return utils.ErrNotFound
+	}
+
+	return nil
+}
+
+// List returns every registered agent, oldest first.
+func (r *agentRepository) List(ctx context.Context) ([]model.Agent, error) {
+	var agents []model.Agent
+	err := r.db.WithContext(ctx).Order("created_at asc").Find(&agents).Error
+	if err != nil {
+		r.log.Error("failed list agents", zap.Error(err))
+		return nil, utils.ErrInternal
+	}
+
+	return agents, nil
+}
+
+// ConfigVersions returns the distinct configuration versions agents last
+// reported to hold.
+func (r *agentRepository) ConfigVersions(ctx context.Context) ([]int, error) {
+	var versions []int
+	err := r.db.WithContext(ctx).
+		Model(&model.Agent{}).
+		Distinct().
+		Pluck("config_version", &versions).Error
+	if err != nil {
+		r.log.Error("failed get agent config versions", zap.Error(err))
+		return nil, utils.ErrInternal
+	}
+
+	return versions, nil
+}
@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	model "distributed-configuration/pkg/models"
+	"distributed-configuration/pkg/utils"
+	"errors"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ConflictMode string
+
+const (
+	ConflictSkip      ConflictMode = "skip"
+	ConflictOverwrite ConflictMode = "overwrite"
+	ConflictFail      ConflictMode = "fail"
+)
+
+type BackupRepository interface {
+	Snapshot(ctx context.Context) ([]model.Configuration, []model.Agent, error)
+	Restore(ctx context.Context, configs []model.Configuration, agents []model.Agent, mode ConflictMode) (model.RestoreResult, error)
+}
+
+type backupRepository struct {
+	db  *gorm.DB
+	log *utils.Logger
+}
+
+func NewBackupRepository(db *gorm.DB, log *utils.Logger) BackupRepository {
+	return &backupRepository{
+		db: db, log: log,
+	}
+}
+
+// Snapshot reads every table inside one read-only repeatable read
+// transaction so the export is consistent while the controller keeps
+// serving.
+func (r *backupRepository) Snapshot(ctx context.Context) ([]model.Configuration, []model.Agent, error) {
+	var (
+		configs []model.Configuration
+		agents  []model.Agent
+	)
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Order("version asc").Find(&configs).Error
+		if err != nil {
+			return err
+		}
+		return tx.Order("created_at asc").Find(&agents).Error
+	}, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		r.log.Error("failed read backup snapshot", zap.Error(err))
+		return nil, nil, utils.ErrInternal
+	}
+
+	return configs, agents, nil
+}
+
+// Restore writes the rows in one transaction. Rows whose version or agent
+// id already exist are skipped, overwritten or abort the restore depending
+// on mode.
+func (r *backupRepository) Restore(
+	ctx context.Context,
+	configs []model.Configuration,
+	agents []model.Agent,
+	mode ConflictMode,
+) (model.RestoreResult, error) {
+	var res model.RestoreResult
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for i := range configs {
+			var count int64
+			err := tx.Model(&model.Configuration{}).Where("version = ?", configs[i].Version).Count(&count).Error
+			if err != nil {
+				return err
+			}
+
+			if count == 0 {
+				configs[i].ID = 0
+				err = tx.Create(&configs[i]).Error
+				if err != nil {
+					return err
+				}
+				res.Inserted++
+				continue
+			}
+
+			switch mode {
+			case ConflictFail:
+				return utils.ErrConflict
+			case ConflictOverwrite:
+				err = tx.Model(&model.Configuration{}).Where("version = ?", configs[i].Version).Updates(map[string]any{
+					"data":       configs[i].Data,
+					"pinned":     configs[i].Pinned,
+					"created_at": configs[i].CreatedAt,
+				}).Error
+				if err != nil {
+					return err
+				}
+				res.Updated++
+			default:
+				res.Skipped++
+			}
+		}
+
+		for i := range agents {
+			var count int64
+			err := tx.Model(&model.Agent{}).Where("id = ?", agents[i].Id).Count(&count).Error
+			if err != nil {
+				return err
+			}
+
+			if count == 0 {
+				err = tx.Create(&agents[i]).Error
+				if err != nil {
+					return err
+				}
+				res.Inserted++
+				continue
+			}
+
+			switch mode {
+			case ConflictFail:
+				return utils.ErrConflict
+			case ConflictOverwrite:
+				err = tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&agents[i]).Error
+				if err != nil {
+					return err
+				}
+				res.Updated++
+			default:
+				res.Skipped++
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		r.log.Error("failed restore backup", zap.Error(err))
+		if errors.Is(err, utils.ErrConflict) {
+			return model.RestoreResult{}, utils.ErrConflict
+		}
+		return model.RestoreResult{}, utils.ErrInternal
+	}
+
+	return res, nil
+}
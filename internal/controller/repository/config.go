@@ -13,7 +13,11 @@ import (
 type ConfigRepository interface {
 	Create(ctx context.Context, config *model.Configuration) error
 	Get(ctx context.Context, config *model.Configuration) error
+	GetVersion(ctx context.Context, version int, config *model.Configuration) error
 	Count(ctx context.Context, config *model.Configuration) (int64, error)
+	List(ctx context.Context) ([]model.Configuration, error)
+	SetPinned(ctx context.Context, version int, pinned bool) error
+	Delete(ctx context.Context, versions []int) error
 }
 
 type configRepository struct {
@@ -31,13 +35,16 @@ func (r *configRepository) Create(ctx context.Context, config *model.Configurati
 	err := r.db.Create(&config).Error
 	if err != nil {
 		r.log.Error("failed create new config", zap.Error(err))
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return utils.ErrConflict
+		}
 		return utils.ErrInternal
 	}
 
 	return nil
 }
 func (r *configRepository) Get(ctx context.Context, config *model.Configuration) error {
-	err := r.db.Last(&config).Error
+	err := r.db.Order("version desc").First(&config).Error
 	if err != nil {
 		r.log.Error("failed get config data", zap.Error(err))
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -49,6 +56,19 @@ func (r *configRepository) Get(ctx context.Context, config *model.Configuration)
 	return nil
 }
 
+func (r *configRepository) GetVersion(ctx context.Context, version int, config *model.Configuration) error {
+	err := r.db.WithContext(ctx).Where("version = ?", version).First(config).Error
+	if err != nil {
+		r.log.Error("failed get config version", zap.Int("version", version), zap.Error(err))
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return utils.ErrNotFound
+		}
+		return utils.ErrInternal
+	}
+
+	return nil
+}
+
 func (r *configRepository) Count(ctx context.Context, config *model.Configuration) (int64, error) {
 	var count int64
 	err := r.db.Model(config).Count(&count).Error
@@ -62,3 +82,50 @@ func (r *configRepository) Count(ctx context.Context, config *model.Configuratio
 
 	return count, nil
 }
+
+// List returns every stored version without its data, newest first.
+func (r *configRepository) List(ctx context.Context) ([]model.Configuration, error) {
+	var configs []model.Configuration
+	err := r.db.WithContext(ctx).
+		Select("id", "version", "pinned", "created_at").
+		Order("version desc").
+		Find(&configs).Error
+	if err != nil {
+		r.log.Error("failed list config versions", zap.Error(err))
+		return nil, utils.ErrInternal
+	}
+
+	return configs, nil
+}
+
+func (r *configRepository) SetPinned(ctx context.Context, version int, pinned bool) error {
+	res := r.db.WithContext(ctx).
+		Model(&model.Configuration{}).
+		Where("version = ?", version).
+		Update("pinned", pinned)
+	if res.Error != nil {
+		r.log.Error("failed update config pin", zap.Error(res.Error))
+		return utils.ErrInternal
+	}
+	if res.RowsAffected == 0 {
+		return utils.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *configRepository) Delete(ctx context.Context, versions []int) error {
+	if len(versions) == 0 {
+		return nil
+	}
+
+	err := r.db.WithContext(ctx).
+		Where("version IN ?", versions).
+		Delete(&model.Configuration{}).Error
+	if err != nil {
+		r.log.Error("failed delete config versions", zap.Error(err))
+		return utils.ErrInternal
+	}
+
+	return nil
+}
@@ -0,0 +1,278 @@
+package repository
+
+import (
+	model "distributed-configuration/pkg/models"
+	"distributed-configuration/pkg/utils"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var ErrSchemaTooNew = errors.New("database schema is newer than this binary")
+
+// Migration is a numbered schema change. Applied versions are recorded in
+// the schema_migrations table so every migration runs exactly once, Down
+// reverts what Up did.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+type schemaMigration struct {
+	Version   int       `gorm:"primaryKey;autoIncrement:false;column:version"`
+	Name      string    `gorm:"column:name"`
+	AppliedAt time.Time `gorm:"column:applied_at"`
+}
+
+func (m *schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Migrations must never be edited once released, add a new one instead.
+// Table snapshots are declared locally so later model changes do not
+// rewrite history.
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "create_agents_and_configurations",
+		Up: func(tx *gorm.DB) error {
+			type agent struct {
+				Id                  string `gorm:"primaryKey"`
+				Name                string
+				Host                string
+				PollIntervalSeconds int
+				CreatedAt           time.Time
+				LastSeen            time.Time
+			}
+			type configuration struct {
+				ID        uint `gorm:"primaryKey;autoIncrement:true"`
+				Version   int  `gorm:"index:idx_configurations_version"`
+				Data      model.JSON
+				CreatedAt time.Time
+			}
+
+			// databases created before migrations existed already have
+			// these tables from AutoMigrate.
+			if !tx.Migrator().HasTable("agents") {
+				err := tx.Table("agents").Migrator().CreateTable(&agent{})
+				if err != nil {
+					return err
+				}
+			}
+			if !tx.Migrator().HasTable("configurations") {
+				err := tx.Table("configurations").Migrator().CreateTable(&configuration{})
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			err := tx.Migrator().DropTable("configurations")
+			if err != nil {
+				return err
+			}
+			return tx.Migrator().DropTable("agents")
+		},
+	},
+	{
+		Version: 2,
+		Name:    "unique_configuration_version",
+		Up: func(tx *gorm.DB) error {
+			if tx.Migrator().HasIndex("configurations", "idx_configurations_version") {
+				err := tx.Migrator().DropIndex("configurations", "idx_configurations_version")
+				if err != nil {
+					return err
+				}
+			}
+			return tx.Exec("CREATE UNIQUE INDEX idx_configurations_version ON configurations (version)").Error
+		},
+		Down: func(tx *gorm.DB) error {
+			err := tx.Migrator().DropIndex("configurations", "idx_configurations_version")
+			if err != nil {
+				return err
+			}
+			return tx.Exec("CREATE INDEX idx_configurations_version ON configurations (version)").Error
+		},
+	},
+	{
+		Version: 3,
+		Name:    "retention_pinned_and_agent_version",
+		Up: func(tx *gorm.DB) error {
+			type configuration struct {
+				Pinned bool `gorm:"not null;default:false"`
+			}
+			type agent struct {
+				ConfigVersion int `gorm:"not null;default:0"`
+			}
+
+			err := tx.Table("configurations").Migrator().AddColumn(&configuration{}, "Pinned")
+			if err != nil {
+				return err
+			}
+			return tx.Table("agents").Migrator().AddColumn(&agent{}, "ConfigVersion")
+		},
+		Down: func(tx *gorm.DB) error {
+			// the sqlite migrator drops a column by recreating the table,
+			// which loses the version index, plain ALTER TABLE keeps it.
+			err := tx.Exec("ALTER TABLE configurations DROP COLUMN pinned").Error
+			if err != nil {
+				return err
+			}
+			return tx.Exec("ALTER TABLE agents DROP COLUMN config_version").Error
+		},
+	},
+}
+
+func LatestMigration() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// MigrateUp applies every pending migration in order.
+func MigrateUp(db *gorm.DB, log *utils.Logger) error {
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return err
+	}
+
+	err = checkSchema(applied)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			err := m.Up(tx)
+			if err != nil {
+				return err
+			}
+			return tx.Create(&schemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("migration %d %s: %w", m.Version, m.Name, err)
+		}
+
+		log.Info("applied migration", zap.Int("version", m.Version), zap.String("name", m.Name))
+	}
+
+	return nil
+}
+
+// MigrateDown reverts the last steps applied migrations, newest first.
+func MigrateDown(db *gorm.DB, log *utils.Logger, steps int) error {
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return err
+	}
+
+	err = checkSchema(applied)
+	if err != nil {
+		return err
+	}
+
+	for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			err := m.Down(tx)
+			if err != nil {
+				return err
+			}
+			return tx.Delete(&schemaMigration{Version: m.Version}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("rollback %d %s: %w", m.Version, m.Name, err)
+		}
+
+		log.Info("reverted migration", zap.Int("version", m.Version), zap.String("name", m.Name))
+		steps--
+	}
+
+	return nil
+}
+
+// Status lists every known migration and when it was applied, followed by
+// applied versions this binary does not know about.
+func Status(db *gorm.DB) ([]MigrationStatus, error) {
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		status := MigrationStatus{Version: m.Version, Name: m.Name}
+		if a, ok := applied[m.Version]; ok {
+			status.AppliedAt = &a.AppliedAt
+		}
+		res = append(res, status)
+		delete(applied, m.Version)
+	}
+
+	for _, a := range applied {
+		res = append(res, MigrationStatus{Version: a.Version, Name: a.Name, AppliedAt: &a.AppliedAt})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Version < res[j].Version })
+
+	return res, nil
+}
+
+// CheckSchema refuses a database migrated by a newer binary.
+func CheckSchema(db *gorm.DB) error {
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return err
+	}
+
+	return checkSchema(applied)
+}
+
+func checkSchema(applied map[int]schemaMigration) error {
+	latest := LatestMigration()
+	for version := range applied {
+		if version > latest {
+			return fmt.Errorf("%w: schema version %d, binary supports up to %d", ErrSchemaTooNew, version, latest)
+		}
+	}
+
+	return nil
+}
+
+func appliedMigrations(db *gorm.DB) (map[int]schemaMigration, error) {
+	err := db.AutoMigrate(&schemaMigration{})
+	if err != nil {
+		return nil, fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	var rows []schemaMigration
+	err = db.Find(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("read schema_migrations: %w", err)
+	}
+
+	applied := make(map[int]schemaMigration, len(rows))
+	for _, m := range rows {
+		applied[m.Version] = m
+	}
+
+	return applied, nil
+}
@@ -0,0 +1,198 @@
+package repository
+
+import (
+	"context"
+	model "distributed-configuration/pkg/models"
+	"distributed-configuration/pkg/utils"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+func newTestDB(t *testing.T) (*gorm.DB, *utils.Logger) {
+	t.Helper()
+
+	log := &utils.Logger{Logger: zap.NewNop()}
+	db, err := NewDatabase(":memory:", log)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+
+	// every connection to :memory: opens a new empty database.
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("sql db: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	return db, log
+}
+
+func newMigratedDB(t *testing.T) (*gorm.DB, *utils.Logger) {
+	t.Helper()
+
+	db, log := newTestDB(t)
+	err := MigrateUp(db, log)
+	if err != nil {
+		t.Fatalf("migrate up: %v", err)
+	}
+	return db, log
+}
+
+func TestConfigRepositoryJSONRoundTrip(t *testing.T) {
+	db, log := newMigratedDB(t)
+	repo := NewConfigRepository(db, log)
+	ctx := context.Background()
+
+	data := `{"db":{"host":"localhost","port":5432},"big":12345678901234567890,"list":[1,"two",null]}`
+	err := repo.Create(ctx, &model.Configuration{Version: 1, Data: model.JSON(data)})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	var latest model.Configuration
+	err = repo.Get(ctx, &latest)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if string(latest.Data) != data {
+		t.Fatalf("data = %s, want %s", latest.Data, data)
+	}
+
+	var version model.Configuration
+	err = repo.GetVersion(ctx, 1, &version)
+	if err != nil {
+		t.Fatalf("get version: %v", err)
+	}
+	if string(version.Data) != data {
+		t.Fatalf("version data = %s, want %s", version.Data, data)
+	}
+
+	err = repo.GetVersion(ctx, 2, &model.Configuration{})
+	if !errors.Is(err, utils.ErrNotFound) {
+		t.Fatalf("get missing version: err = %v, want %v", err, utils.ErrNotFound)
+	}
+}
+
+func TestConfigRepositoryUniqueVersion(t *testing.T) {
+	db, log := newMigratedDB(t)
+	repo := NewConfigRepository(db, log)
+	ctx := context.Background()
+
+	err := repo.Create(ctx, &model.Configuration{Version: 1, Data: model.JSON(`{"a":1}`)})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	err = repo.Create(ctx, &model.Configuration{Version: 1, Data: model.JSON(`{"a":2}`)})
+	if !errors.Is(err, utils.ErrConflict) {
+		t.Fatalf("create duplicate: err = %v, want %v", err, utils.ErrConflict)
+	}
+
+	count, err := repo.Count(ctx, &model.Configuration{})
+	if err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("count = %d, want 1", count)
+	}
+}
+
+func TestMigrateUpDown(t *testing.T) {
+	db, log := newTestDB(t)
+
+	err := MigrateUp(db, log)
+	if err != nil {
+		t.Fatalf("migrate up: %v", err)
+	}
+	// applying again is a no-op.
+	err = MigrateUp(db, log)
+	if err != nil {
+		t.Fatalf("migrate up again: %v", err)
+	}
+
+	status, err := Status(db)
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if len(status) != len(migrations) {
+		t.Fatalf("status has %d entries, want %d", len(status), len(migrations))
+	}
+	for _, s := range status {
+		if s.AppliedAt == nil {
+			t.Fatalf("migration %d not applied", s.Version)
+		}
+	}
+	if !db.Migrator().HasColumn("configurations", "pinned") {
+		t.Fatal("configurations.pinned missing after migrate up")
+	}
+
+	err = MigrateDown(db, log, 1)
+	if err != nil {
+		t.Fatalf("migrate down: %v", err)
+	}
+	if db.Migrator().HasColumn("configurations", "pinned") {
+		t.Fatal("configurations.pinned still present after migrate down")
+	}
+	if !db.Migrator().HasIndex("configurations", "idx_configurations_version") {
+		t.Fatal("version index lost after migrate down")
+	}
+
+	status, err = Status(db)
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	last := status[len(status)-1]
+	if last.Version != LatestMigration() || last.AppliedAt != nil {
+		t.Fatalf("latest migration status = %+v, want not applied", last)
+	}
+
+	err = MigrateDown(db, log, len(migrations))
+	if err != nil {
+		t.Fatalf("migrate down all: %v", err)
+	}
+	if db.Migrator().HasTable("configurations") || db.Migrator().HasTable("agents") {
+		t.Fatal("tables still present after migrating all the way down")
+	}
+
+	err = MigrateUp(db, log)
+	if err != nil {
+		t.Fatalf("migrate up after down: %v", err)
+	}
+}
+
+func TestMigrateSchemaTooNew(t *testing.T) {
+	db, log := newMigratedDB(t)
+
+	future := LatestMigration() + 1
+	err := db.Create(&schemaMigration{Version: future, Name: "from_the_future", AppliedAt: time.Now()}).Error
+	if err != nil {
+		t.Fatalf("insert migration: %v", err)
+	}
+
+	err = MigrateUp(db, log)
+	if !errors.Is(err, ErrSchemaTooNew) {
+		t.Fatalf("migrate up: err = %v, want %v", err, ErrSchemaTooNew)
+	}
+	err = MigrateDown(db, log, 1)
+	if !errors.Is(err, ErrSchemaTooNew) {
+		t.Fatalf("migrate down: err = %v, want %v", err, ErrSchemaTooNew)
+	}
+	err = CheckSchema(db)
+	if !errors.Is(err, ErrSchemaTooNew) {
+		t.Fatalf("check schema: err = %v, want %v", err, ErrSchemaTooNew)
+	}
+
+	status, err := Status(db)
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	last := status[len(status)-1]
+	if last.Version != future || last.Name != "from_the_future" || last.AppliedAt == nil {
+		t.Fatalf("unknown migration status = %+v", last)
+	}
+}
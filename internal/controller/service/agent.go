@@ -6,6 +6,7 @@ import (
 	"distributed-configuration/internal/controller/repository"
 	model "distributed-configuration/pkg/models"
 	"distributed-configuration/pkg/utils"
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
@@ -14,7 +15,9 @@ import (
 
 type AgentService interface {
 	Register(ctx context.Context, req *model.AgentRequest) (string, error)
-	Verify(ctx context.Context, agentID string) error
+	Verify(ctx context.Context, agentID string, version int) error
+	Deregister(ctx context.Context, agentID string) error
+	List(ctx context.Context) ([]model.Agent, error)
 }
 
 type agentService struct {
@@ -39,6 +42,7 @@ func (s *agentService) Register(ctx context.Context, req *model.AgentRequest) (s
 		Name:                req.Name,
 		Host:                req.Host,
 		PollIntervalSeconds: int(s.cfg.PollInterval.Seconds()),
+		ConfigVersion:       utils.ParseVersion(req.ETag),
 		CreatedAt:           time.Now(),
 		LastSeen:            time.Now(),
 	}
@@ -51,15 +55,21 @@ func (s *agentService) Register(ctx context.Context, req *model.AgentRequest) (s
 	return agentID, nil
 }
 
-func (s *agentService) Verify(ctx context.Context, agentID string) error {
+// Verify checks the agent is registered and records the config version it
+// currently holds.
+func (s *agentService) Verify(ctx context.Context, agentID string, version int) error {
 	agent := model.Agent{Id: agentID}
 	err := s.repo.Get(ctx, &agent)
 	if err != nil {
 		s.log.Error("failed get agent data", zap.Error(err))
+		if errors.Is(err, utils.ErrNotFound) {
+			return utils.ErrUnknownAgent
+		}
 		return err
 	}
 
 	agent.LastSeen = time.Now()
+	agent.ConfigVersion = version
 	err = s.repo.Update(ctx, &agent)
 	if err != nil {
 		s.log.Error("failed get agent data", zap.Error(err))
@@ -68,3 +78,25 @@ func (s *agentService) Verify(ctx context.Context, agentID string) error {
 
 	return nil
 }
+
+// Deregister removes an agent that is shutting down for good.
+func (s *agentService) Deregister(ctx context.Context, agentID string) error {
+	err := s.repo.Delete(ctx, agentID)
+	if err != nil {
+		s.log.Error("failed deregister agent", zap.String("agent_id", agentID), zap.Error(err))
+		return err
+	}
+
+	s.log.Info("agent deregistered", zap.String("agent_id", agentID))
+	return nil
+}
+
+func (s *agentService) List(ctx context.Context) ([]model.Agent, error) {
+	agents, err := s.repo.List(ctx)
+	if err != nil {
+		s.log.Error("failed list agents", zap.Error(err))
+		return nil, err
+	}
+
+	return agents, nil
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"compress/gzip"
+	"context"
+	"distributed-configuration/internal/controller/repository"
+	model "distributed-configuration/pkg/models"
+	"distributed-configuration/pkg/utils"
+	"encoding/json"
+	"io"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const backupFormatVersion = 1
+
+type BackupService interface {
+	Export(ctx context.Context, w io.Writer) error
+	Import(ctx context.Context, r io.Reader, mode repository.ConflictMode) (model.RestoreResult, error)
+}
+
+type backupService struct {
+	log    *utils.Logger
+	repo   repository.BackupRepository
+	config ConfigService
+	notif  *RedisNotifier
+}
+
+func NewBackupService(
+	log *utils.Logger,
+	repo repository.BackupRepository,
+	config ConfigService,
+	notif *RedisNotifier,
+) BackupService {
+	return &backupService{
+		log:    log,
+		repo:   repo,
+		config: config,
+		notif:  notif,
+	}
+}
+
+// Export writes the whole controller state as a gzip compressed JSON archive.
+func (s *backupService) Export(ctx context.Context, w io.Writer) error {
+	configs, agents, err := s.repo.Snapshot(ctx)
+	if err != nil {
+		s.log.Error("failed read backup snapshot", zap.Error(err))
+		return err
+	}
+
+	backup := model.Backup{
+		FormatVersion:  backupFormatVersion,
+		SchemaVersion:  repository.LatestMigration(),
+		CreatedAt:      time.Now(),
+		Configurations: make([]model.BackupConfiguration, 0, len(configs)),
+		Agents:         agents,
+	}
+	for _, c := range configs {
+		backup.Configurations = append(backup.Configurations, model.BackupConfiguration{
+			Version:   c.Version,
+			Data:      c.Data,
+			Pinned:    c.Pinned,
+			CreatedAt: c.CreatedAt,
+		})
+	}
+
+	gz := gzip.NewWriter(w)
+	err = json.NewEncoder(gz).Encode(backup)
+	if err != nil {
+		s.log.Error("failed encode backup", zap.Error(err))
+		return utils.ErrInternal
+	}
+
+	return gz.Close()
+}
+
+// Import restores an archive produced by Export.
+func (s *backupService) Import(ctx context.Context, r io.Reader, mode repository.ConflictMode) (model.RestoreResult, error) {
+	switch mode {
+	case repository.ConflictSkip, repository.ConflictOverwrite, repository.ConflictFail:
+	case "":
+		mode = repository.ConflictSkip
+	default:
+		return model.RestoreResult{}, utils.ErrInvalidInput
+	}
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		s.log.Error("invalid backup archive", zap.Error(err))
+		return model.RestoreResult{}, utils.ErrInvalidInput
+	}
+	defer gz.Close()
+
+	var backup model.Backup
+	err = json.NewDecoder(gz).Decode(&backup)
+	if err != nil {
+		s.log.Error("invalid backup archive", zap.Error(err))
+		return model.RestoreResult{}, utils.ErrInvalidInput
+	}
+
+	if backup.FormatVersion != backupFormatVersion || backup.SchemaVersion > repository.LatestMigration() {
+		s.log.Error(
+			"unsupported backup archive",
+			zap.Int("format_version", backup.FormatVersion),
+			zap.Int("schema_version", backup.SchemaVersion),
+		)
+		return model.RestoreResult{}, utils.ErrInvalidInput
+	}
+
+	configs := make([]model.Configuration, 0, len(backup.Configurations))
+	for _, c := range backup.Configurations {
+		configs = append(configs, model.Configuration{
+			Version:   c.Version,
+			Data:      c.Data,
+			Pinned:    c.Pinned,
+			CreatedAt: c.CreatedAt,
+		})
+	}
+
+	res, err := s.repo.Restore(ctx, configs, backup.Agents, mode)
+	if err != nil {
+		s.log.Error("failed restore backup", zap.Error(err))
+		return model.RestoreResult{}, err
+	}
+
+	s.config.Invalidate()
+	s.log.Info(
+		"backup restored",
+		zap.Int("inserted", res.Inserted),
+		zap.Int("updated", res.Updated),
+		zap.Int("skipped", res.Skipped),
+	)
+	s.publish(ctx)
+
+	return res, nil
+}
+
+// publish tells the other replicas to drop their cached configuration and
+// wakes waiting long-polls, the restore may have rewritten versions they
+// hold.
+func (s *backupService) publish(ctx context.Context) {
+	update := model.ConfigUpdate{
+		Namespace:  utils.DefaultNamespace,
+		Invalidate: true,
+	}
+	config, err := s.config.Version(ctx, 0)
+	if err == nil {
+		update.Version = config.Version
+		update.Hash = config.Hash()
+	}
+
+	err = s.notif.PublishUpdate(ctx, update)
+	if err != nil {
+		s.log.Error("failed to publish restore", zap.Error(err))
+	}
+}
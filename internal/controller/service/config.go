@@ -7,23 +7,41 @@ import (
 	"distributed-configuration/pkg/utils"
 	"encoding/json"
 	"reflect"
-	"regexp"
-	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"go.uber.org/zap"
 )
 
 type ConfigService interface {
-	Save(ctx context.Context, req *model.Configuration) error
+	Save(ctx context.Context, req *model.Configuration, ifMatch string) (model.Configuration, error)
 	Get(ctx context.Context, version string) (model.Configuration, error)
+	Select(ctx context.Context, version string, pointers []string) (model.Configuration, string, error)
+	Version(ctx context.Context, version int) (model.Configuration, error)
+	History(ctx context.Context) ([]model.Configuration, error)
+	Rollback(ctx context.Context, version int) (model.Configuration, error)
+	Observe(update model.ConfigUpdate)
+	Invalidate()
 }
 
 type configService struct {
 	log  *utils.Logger
 	repo repository.ConfigRepository
+
+	// latest caches the newest configuration so that waking many long-poll
+	// subscribers at once results in a single database read. It is read
+	// again after LatestTTL, so a replica that missed a pub/sub message
+	// serves the new version at the next poll.
+	mu      sync.Mutex
+	latest  *model.Configuration
+	fetched time.Time
+	known   int
 }
 
+// LatestTTL bounds how long a replica may serve a stale latest version.
+const LatestTTL = 5 * time.Second
+
 func NewConfigService(log *utils.Logger, repo repository.ConfigRepository) ConfigService {
 	return &configService{
 		log:  log,
@@ -31,17 +49,23 @@ func NewConfigService(log *utils.Logger, repo repository.ConfigRepository) Confi
 	}
 }
 
-func (s *configService) Save(ctx context.Context, req *model.Configuration) error {
-	var (
-		config           model.Configuration
-		newData, oldData map[string]any
-	)
+// Save stores req as the next version. A non-empty ifMatch, the If-Match
+// header, must match the latest version or ErrPreconditionFailed is returned,
+// two writers racing past that check still fail with ErrConflict on the
+// unique version.
+func (s *configService) Save(ctx context.Context, req *model.Configuration, ifMatch string) (model.Configuration, error) {
+	var config model.Configuration
 
 	count, err := s.repo.Count(ctx, &config)
 	if err != nil {
 		s.log.Error("failed get latest config", zap.Error(err))
-		return err
+		return model.Configuration{}, err
 	} else if count == 0 {
+		// v0 stands for "nothing saved yet", "*" requires an existing version.
+		if ifMatch != "" && (strings.TrimSpace(ifMatch) == "*" || !utils.MatchETag(ifMatch, utils.FormatVersion(0))) {
+			return model.Configuration{}, utils.ErrPreconditionFailed
+		}
+
 		newConfig := model.Configuration{
 			Version:   1,
 			Data:      req.Data,
@@ -50,10 +74,11 @@ func (s *configService) Save(ctx context.Context, req *model.Configuration) erro
 		err = s.repo.Create(ctx, &newConfig)
 		if err != nil {
 			s.log.Error("failed create new config", zap.Error(err))
-			return err
+			return model.Configuration{}, err
 		}
 
-		return nil
+		s.setLatest(newConfig)
+		return newConfig, nil
 	}
 
 	s.log.Info("total data", zap.Int("count", int(count)))
@@ -61,16 +86,23 @@ func (s *configService) Save(ctx context.Context, req *model.Configuration) erro
 	err = s.repo.Get(ctx, &config)
 	if err != nil {
 		s.log.Error("failed get latest config", zap.Error(err))
-		return err
+		return model.Configuration{}, err
+	}
+
+	if ifMatch != "" && !utils.MatchETag(ifMatch, utils.FormatVersion(config.Version)) {
+		s.log.Warn("stale configuration version", zap.String("if_match", ifMatch), zap.Int("latest", config.Version))
+		return model.Configuration{}, utils.ErrPreconditionFailed
 	}
 
-	json.Unmarshal(req.Data, &newData)
-	json.Unmarshal(config.Data, &oldData)
+	// compare with json.Number so numbers differing only beyond float64
+	// precision still count as a change.
+	newData, _ := utils.DecodeJSON(req.Data)
+	oldData, _ := utils.DecodeJSON(config.Data)
 
 	ok := reflect.DeepEqual(newData, oldData)
 	if ok {
 		s.log.Info("data not modified")
-		return utils.ErrNotModified
+		return model.Configuration{}, utils.ErrNotModified
 	}
 
 	newConfig := model.Configuration{
@@ -81,27 +113,171 @@ func (s *configService) Save(ctx context.Context, req *model.Configuration) erro
 	err = s.repo.Create(ctx, &newConfig)
 	if err != nil {
 		s.log.Error("failed create new config", zap.Error(err))
-		return err
+		return model.Configuration{}, err
 	}
 
-	return nil
+	s.setLatest(newConfig)
+	return newConfig, nil
 }
 
 func (s *configService) Get(ctx context.Context, version string) (model.Configuration, error) {
-	var config model.Configuration
-
-	err := s.repo.Get(ctx, &config)
+	config, err := s.getLatest(ctx)
 	if err != nil {
 		s.log.Error("failed get latest config", zap.Error(err))
 		return model.Configuration{}, err
 	}
 
-	versionStr := regexp.MustCompile(`\d+`).FindString(version)
-	versionInt, _ := strconv.Atoi(versionStr)
-	if versionInt == config.Version {
+	if utils.ParseVersion(version) == config.Version && utils.SubtreeHash(version) == "" {
 		s.log.Warn("data not modified")
 		return model.Configuration{}, utils.ErrNotModified
 	}
 
 	return config, nil
 }
+
+// Select returns the latest configuration narrowed to the JSON Pointers,
+// which address the data delivered to agents, along with its subtree ETag.
+// It reports ErrNotModified while the selected subtree is unchanged, even
+// when other parts of the configuration moved to a newer version.
+func (s *configService) Select(ctx context.Context, version string, pointers []string) (model.Configuration, string, error) {
+	config, err := s.getLatest(ctx)
+	if err != nil {
+		s.log.Error("failed get latest config", zap.Error(err))
+		return model.Configuration{}, "", err
+	}
+
+	var envelope map[string]json.RawMessage
+	json.Unmarshal(config.Data, &envelope)
+	data := envelope["data"]
+	if len(data) == 0 {
+		data = json.RawMessage("null")
+	}
+
+	subtree, err := utils.SelectJSON(data, pointers)
+	if err != nil {
+		s.log.Error("failed select config", zap.Strings("select", pointers), zap.Error(err))
+		return model.Configuration{}, "", err
+	}
+
+	etag := utils.FormatSubtreeVersion(config.Version, subtree)
+	if utils.SubtreeHash(etag) == utils.SubtreeHash(version) {
+		return model.Configuration{}, "", utils.ErrNotModified
+	}
+
+	config.Data, err = json.Marshal(map[string]json.RawMessage{"data": subtree})
+	if err != nil {
+		return model.Configuration{}, "", err
+	}
+
+	return config, etag, nil
+}
+
+// Version returns the stored configuration version, the latest one for 0.
+func (s *configService) Version(ctx context.Context, version int) (model.Configuration, error) {
+	if version == 0 {
+		config, err := s.getLatest(ctx)
+		if err != nil {
+			s.log.Error("failed get latest config", zap.Error(err))
+			return model.Configuration{}, err
+		}
+		return config, nil
+	}
+
+	var config model.Configuration
+	err := s.repo.GetVersion(ctx, version, &config)
+	if err != nil {
+		s.log.Error("failed get config version", zap.Int("version", version), zap.Error(err))
+		return model.Configuration{}, err
+	}
+
+	return config, nil
+}
+
+// History lists every stored version without its data, newest first.
+func (s *configService) History(ctx context.Context) ([]model.Configuration, error) {
+	configs, err := s.repo.List(ctx)
+	if err != nil {
+		s.log.Error("failed list config versions", zap.Error(err))
+		return nil, err
+	}
+
+	return configs, nil
+}
+
+// Rollback saves the data of an earlier version as a new version, so agents
+// pick it up like any other update. It reports ErrNotModified when that data
+// is already the latest.
+func (s *configService) Rollback(ctx context.Context, version int) (model.Configuration, error) {
+	var old model.Configuration
+	err := s.repo.GetVersion(ctx, version, &old)
+	if err != nil {
+		s.log.Error("failed get config version", zap.Int("version", version), zap.Error(err))
+		return model.Configuration{}, err
+	}
+
+	config, err := s.Save(ctx, &model.Configuration{Data: old.Data}, "")
+	if err != nil {
+		return model.Configuration{}, err
+	}
+
+	s.log.Info("config rolled back", zap.Int("from", version), zap.Int("version", config.Version))
+	return config, nil
+}
+
+// Observe records a version announced by any controller replica, so the
+// cached configuration is reloaded once it falls behind.
+func (s *configService) Observe(update model.ConfigUpdate) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if update.Version > s.known {
+		s.known = update.Version
+	}
+}
+
+// Invalidate drops the cached configuration, e.g. after a restore rewrote
+// existing versions.
+func (s *configService) Invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.latest = nil
+}
+
+func (s *configService) getLatest(ctx context.Context) (model.Configuration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.latest != nil && s.latest.Version >= s.known && time.Since(s.fetched) < LatestTTL {
+		return *s.latest, nil
+	}
+
+	var config model.Configuration
+	err := s.repo.Get(ctx, &config)
+	if err != nil {
+		return model.Configuration{}, err
+	}
+
+	s.latest = &config
+	s.fetched = time.Now()
+	if config.Version > s.known {
+		s.known = config.Version
+	}
+
+	return config, nil
+}
+
+func (s *configService) setLatest(config model.Configuration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.latest != nil && s.latest.Version > config.Version {
+		return
+	}
+
+	s.latest = &config
+	s.fetched = time.Now()
+	if config.Version > s.known {
+		s.known = config.Version
+	}
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"distributed-configuration/internal/controller/repository"
+	model "distributed-configuration/pkg/models"
+	"distributed-configuration/pkg/utils"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func newTestConfigService(t *testing.T) ConfigService {
+	t.Helper()
+
+	log := &utils.Logger{Logger: zap.NewNop()}
+	db, err := repository.NewDatabase(":memory:", log)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+
+	// every connection to :memory: opens a new empty database.
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("sql db: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	err = repository.MigrateUp(db, log)
+	if err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	return NewConfigService(log, repository.NewConfigRepository(db, log))
+}
+
+func TestSaveIfMatch(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestConfigService(t)
+	config := func(data string) *model.Configuration {
+		return &model.Configuration{Data: model.JSON(data)}
+	}
+
+	_, err := svc.Save(ctx, config(`{"a":1}`), "*")
+	if !errors.Is(err, utils.ErrPreconditionFailed) {
+		t.Fatalf("save * without a version: err = %v, want ErrPreconditionFailed", err)
+	}
+
+	saved, err := svc.Save(ctx, config(`{"a":1}`), `"v0"`)
+	if err != nil || saved.Version != 1 {
+		t.Fatalf("save v0: version %d, err %v", saved.Version, err)
+	}
+
+	_, err = svc.Save(ctx, config(`{"a":2}`), `"v0"`)
+	if !errors.Is(err, utils.ErrPreconditionFailed) {
+		t.Fatalf("save stale v0: err = %v, want ErrPreconditionFailed", err)
+	}
+
+	saved, err = svc.Save(ctx, config(`{"a":2}`), `"v1"`)
+	if err != nil || saved.Version != 2 {
+		t.Fatalf("save v1: version %d, err %v", saved.Version, err)
+	}
+
+	_, err = svc.Save(ctx, config(`{"a":3}`), `"v1"`)
+	if !errors.Is(err, utils.ErrPreconditionFailed) {
+		t.Fatalf("save stale v1: err = %v, want ErrPreconditionFailed", err)
+	}
+
+	saved, err = svc.Save(ctx, config(`{"a":3}`), "")
+	if err != nil || saved.Version != 3 {
+		t.Fatalf("save without If-Match: version %d, err %v", saved.Version, err)
+	}
+}
+
+func TestGetLatestExpires(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestConfigService(t)
+
+	_, err := svc.Save(ctx, &model.Configuration{Data: model.JSON(`{"a":1}`)}, "")
+	if err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	// another replica saves version 2, its pub/sub message gets lost.
+	cached := svc.(*configService)
+	err = cached.repo.Create(ctx, &model.Configuration{Version: 2, Data: model.JSON(`{"a":2}`)})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	config, err := svc.Get(ctx, "")
+	if err != nil || config.Version != 1 {
+		t.Fatalf("cached get: version %d, err %v, want the cached version 1", config.Version, err)
+	}
+
+	cached.mu.Lock()
+	cached.fetched = time.Now().Add(-LatestTTL)
+	cached.mu.Unlock()
+
+	config, err = svc.Get(ctx, "")
+	if err != nil || config.Version != 2 {
+		t.Fatalf("expired get: version %d, err %v, want 2", config.Version, err)
+	}
+}
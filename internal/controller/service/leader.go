@@ -0,0 +1,202 @@
+package service
+
+import (
+	"context"
+	model "distributed-configuration/pkg/models"
+	"distributed-configuration/pkg/utils"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// renew and release only touch the lease while this replica still owns it.
+var (
+	renewScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+	releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+)
+
+// LeaderElector elects one controller replica through a Redis lease. Only
+// the leader runs background jobs, a dead leader is replaced once its
+// lease expires, so failover takes at most the lease TTL.
+type LeaderElector struct {
+	rdb *redis.Client
+	log *utils.Logger
+	key string
+	id  string
+	ttl time.Duration
+
+	mu       sync.RWMutex
+	leader   bool
+	leaderID string
+}
+
+func NewLeaderElector(rds *redis.Client, key, replicaID string, ttl time.Duration, log *utils.Logger) *LeaderElector {
+	if key == "" {
+		key = "controller-leader"
+	}
+	if replicaID == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			hostname = "controller"
+		}
+		replicaID = hostname + "-" + uuid.NewString()[:8]
+	}
+	if ttl <= 0 {
+		ttl = 15 * time.Second
+	}
+
+	return &LeaderElector{
+		rdb: rds,
+		log: log,
+		key: key,
+		id:  replicaID,
+		ttl: ttl,
+	}
+}
+
+// Run campaigns for leadership until ctx is done. jobs are started when
+// this replica becomes leader and cancelled when it loses the lease.
+func (l *LeaderElector) Run(ctx context.Context, jobs ...func(ctx context.Context)) {
+	var (
+		cancelJobs context.CancelFunc
+		wg         sync.WaitGroup
+	)
+
+	startJobs := func() context.CancelFunc {
+		jobCtx, cancel := context.WithCancel(ctx)
+		for _, job := range jobs {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				job(jobCtx)
+			}()
+		}
+		return cancel
+	}
+
+	stopJobs := func() {
+		if cancelJobs != nil {
+			cancelJobs()
+			wg.Wait()
+			cancelJobs = nil
+		}
+	}
+
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		leader := l.campaign(ctx)
+		switch {
+		case leader && cancelJobs == nil:
+			l.log.Info("acquired leadership", zap.String("replica_id", l.id))
+			cancelJobs = startJobs()
+		case !leader && cancelJobs != nil:
+			l.log.Warn("lost leadership", zap.String("replica_id", l.id))
+			stopJobs()
+		}
+
+		select {
+		case <-ctx.Done():
+			stopJobs()
+			l.resign()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// campaign acquires or renews the lease and reports whether this replica
+// holds it. Every attempt is bounded by a third of the TTL: the lease was
+// last renewed at most a third earlier, so a failed or hanging renewal makes
+// the leader step down before the lease can expire and another replica
+// take over.
+func (l *LeaderElector) campaign(ctx context.Context) bool {
+	ctx, cancel := context.WithTimeout(ctx, l.ttl/3)
+	defer cancel()
+
+	l.mu.RLock()
+	wasLeader := l.leader
+	l.mu.RUnlock()
+
+	var (
+		leader bool
+		err    error
+	)
+	if wasLeader {
+		var renewed int64
+		renewed, err = renewScript.Run(ctx, l.rdb, []string{l.key}, l.id, l.ttl.Milliseconds()).Int64()
+		leader = err == nil && renewed == 1
+	} else {
+		leader, err = l.rdb.SetNX(ctx, l.key, l.id, l.ttl).Result()
+	}
+	if err != nil {
+		if wasLeader {
+			l.log.Error("lease renewal failed, stepping down", zap.String("replica_id", l.id), zap.Error(err))
+		} else {
+			l.log.Error("leader election failed", zap.Error(err))
+		}
+	}
+
+	leaderID := l.id
+	if !leader {
+		leaderID, _ = l.rdb.Get(ctx, l.key).Result()
+	}
+
+	l.mu.Lock()
+	l.leader = leader
+	l.leaderID = leaderID
+	l.mu.Unlock()
+
+	return leader
+}
+
+func (l *LeaderElector) resign() {
+	l.mu.Lock()
+	leader := l.leader
+	l.leader = false
+	l.leaderID = ""
+	l.mu.Unlock()
+
+	if !leader {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	err := releaseScript.Run(ctx, l.rdb, []string{l.key}, l.id).Err()
+	if err != nil {
+		l.log.Error("failed release leadership", zap.Error(err))
+		return
+	}
+	l.log.Info("released leadership", zap.String("replica_id", l.id))
+}
+
+func (l *LeaderElector) Status() model.ReplicaStatus {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	role := utils.RoleFollower
+	if l.leader {
+		role = utils.RoleLeader
+	}
+
+	return model.ReplicaStatus{
+		ReplicaID: l.id,
+		Role:      role,
+		LeaderID:  l.leaderID,
+	}
+}
@@ -2,7 +2,9 @@ package service
 
 import (
 	"context"
+	model "distributed-configuration/pkg/models"
 	"distributed-configuration/pkg/utils"
+	"encoding/json"
 	"sync"
 	"time"
 
@@ -10,19 +12,26 @@ import (
 	"go.uber.org/zap"
 )
 
+type listener struct {
+	version int
+	ch      chan model.ConfigUpdate
+}
+
 type RedisNotifier struct {
 	rdb        *redis.Client
 	log        *utils.Logger
+	config     ConfigService
 	mu         sync.RWMutex
-	listeners  []chan struct{}
+	listeners  map[string][]*listener
 	channelKey string
 }
 
-func NewRedisNotifier(rds *redis.Client, channelKey string, log *utils.Logger) *RedisNotifier {
+func NewRedisNotifier(rds *redis.Client, channelKey string, config ConfigService, log *utils.Logger) *RedisNotifier {
 	rn := &RedisNotifier{
 		rdb:        rds,
 		channelKey: channelKey,
-		listeners:  make([]chan struct{}, 0),
+		config:     config,
+		listeners:  make(map[string][]*listener),
 		log:        log,
 	}
 
@@ -48,8 +57,23 @@ func (r *RedisNotifier) listenGlobalUpdates() {
 
 		ch := pubsub.Channel()
 		for msg := range ch {
-			r.log.Info("redis received update signal", zap.String("message", msg.String()))
-			r.broadcastToLocal()
+			var update model.ConfigUpdate
+			err := json.Unmarshal([]byte(msg.Payload), &update)
+			if err != nil {
+				r.log.Warn("redis received invalid update signal", zap.String("message", msg.Payload), zap.Error(err))
+				continue
+			}
+
+			r.log.Info(
+				"redis received update signal",
+				zap.String("namespace", update.Namespace),
+				zap.Int("version", update.Version),
+			)
+			if update.Invalidate {
+				r.config.Invalidate()
+			}
+			r.config.Observe(update)
+			r.broadcastToLocal(update)
 		}
 
 		pubsub.Close()
@@ -58,31 +82,76 @@ func (r *RedisNotifier) listenGlobalUpdates() {
 	}
 }
 
-func (r *RedisNotifier) broadcastToLocal() {
+// broadcastToLocal wakes only the listeners of the updated namespace that
+// are still waiting on an older version, the rest keep waiting. An
+// invalidation wakes all of them.
+func (r *RedisNotifier) broadcastToLocal(update model.ConfigUpdate) {
 	r.mu.Lock()
-	currentListeners := r.listeners
-	r.listeners = make([]chan struct{}, 0)
+	var (
+		waiting []*listener
+		wake    []*listener
+	)
+	for _, l := range r.listeners[update.Namespace] {
+		if update.Invalidate || l.version < update.Version {
+			wake = append(wake, l)
+			continue
+		}
+		waiting = append(waiting, l)
+	}
+	if len(waiting) == 0 {
+		delete(r.listeners, update.Namespace)
+	} else {
+		r.listeners[update.Namespace] = waiting
+	}
 	r.mu.Unlock()
 
-	for _, ch := range currentListeners {
+	for _, l := range wake {
 		select {
-		case ch <- struct{}{}:
+		case l.ch <- update:
 		default:
 		}
 
-		close(ch)
+		close(l.ch)
 	}
 }
 
-func (r *RedisNotifier) PublishUpdate(ctx context.Context) error {
-	r.log.Info("publishing global update signal", zap.String("channel", r.channelKey))
-	return r.rdb.Publish(ctx, r.channelKey, "updated").Err()
+func (r *RedisNotifier) PublishUpdate(ctx context.Context, update model.ConfigUpdate) error {
+	payload, err := json.Marshal(update)
+	if err != nil {
+		return err
+	}
+
+	r.log.Info(
+		"publishing global update signal",
+		zap.String("channel", r.channelKey),
+		zap.String("namespace", update.Namespace),
+		zap.Int("version", update.Version),
+	)
+	return r.rdb.Publish(ctx, r.channelKey, payload).Err()
 }
 
-func (r *RedisNotifier) Subscribe() chan struct{} {
+// Subscribe returns a channel that receives the first update of namespace
+// newer than version.
+func (r *RedisNotifier) Subscribe(namespace string, version int) chan model.ConfigUpdate {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	ch := make(chan struct{}, 1)
-	r.listeners = append(r.listeners, ch)
+	ch := make(chan model.ConfigUpdate, 1)
+	r.listeners[namespace] = append(r.listeners[namespace], &listener{version: version, ch: ch})
 	return ch
 }
+
+func (r *RedisNotifier) Unsubscribe(namespace string, ch chan model.ConfigUpdate) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	listeners := r.listeners[namespace]
+	for i, l := range listeners {
+		if l.ch == ch {
+			r.listeners[namespace] = append(listeners[:i], listeners[i+1:]...)
+			break
+		}
+	}
+	if len(r.listeners[namespace]) == 0 {
+		delete(r.listeners, namespace)
+	}
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"distributed-configuration/internal/controller/config"
+	"distributed-configuration/internal/controller/repository"
+	"distributed-configuration/pkg/utils"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type RetentionService interface {
+	Pin(ctx context.Context, version int, pinned bool) error
+	Prune(ctx context.Context, dryRun bool) ([]int, error)
+	Run(ctx context.Context)
+}
+
+type retentionService struct {
+	log    *utils.Logger
+	config repository.ConfigRepository
+	agent  repository.AgentRepository
+	cfg    *config.Config
+}
+
+func NewRetentionService(
+	log *utils.Logger,
+	config repository.ConfigRepository,
+	agent repository.AgentRepository,
+	cfg *config.Config,
+) RetentionService {
+	return &retentionService{
+		log:    log,
+		config: config,
+		agent:  agent,
+		cfg:    cfg,
+	}
+}
+
+func (s *retentionService) Pin(ctx context.Context, version int, pinned bool) error {
+	err := s.config.SetPinned(ctx, version, pinned)
+	if err != nil {
+		s.log.Error("failed update config pin", zap.Int("version", version), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+// Prune deletes the versions outside the retention policy and returns them.
+// The latest version, pinned versions and versions still held by an agent
+// are always kept. With dryRun nothing is deleted.
+func (s *retentionService) Prune(ctx context.Context, dryRun bool) ([]int, error) {
+	if s.cfg.RetentionKeepLast <= 0 && s.cfg.RetentionKeepDays <= 0 {
+		return []int{}, nil
+	}
+
+	configs, err := s.config.List(ctx)
+	if err != nil {
+		s.log.Error("failed list config versions", zap.Error(err))
+		return nil, err
+	}
+
+	inUse, err := s.agent.ConfigVersions(ctx)
+	if err != nil {
+		s.log.Error("failed get agent config versions", zap.Error(err))
+		return nil, err
+	}
+
+	used := make(map[int]struct{}, len(inUse))
+	for _, v := range inUse {
+		used[v] = struct{}{}
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -s.cfg.RetentionKeepDays)
+	prune := []int{}
+	for i, c := range configs {
+		if _, ok := used[c.Version]; ok || i == 0 || c.Pinned {
+			continue
+		}
+		if s.cfg.RetentionKeepLast > 0 && i < s.cfg.RetentionKeepLast {
+			continue
+		}
+		if s.cfg.RetentionKeepDays > 0 && c.CreatedAt.After(cutoff) {
+			continue
+		}
+		prune = append(prune, c.Version)
+	}
+
+	if dryRun || len(prune) == 0 {
+		return prune, nil
+	}
+
+	err = s.config.Delete(ctx, prune)
+	if err != nil {
+		s.log.Error("failed prune config versions", zap.Error(err))
+		return nil, err
+	}
+
+	s.log.Info("pruned config versions", zap.Ints("versions", prune))
+	return prune, nil
+}
+
+// Run prunes on every retention interval until ctx is done.
+func (s *retentionService) Run(ctx context.Context) {
+	interval := s.cfg.RetentionInterval
+	if interval <= 0 {
+		interval = time.Hour
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, err := s.Prune(ctx, false)
+			if err != nil {
+				s.log.Error("retention job failed", zap.Error(err))
+			}
+		}
+	}
+}
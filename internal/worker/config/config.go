@@ -11,6 +11,8 @@ type Config struct {
 	WorkerSecret string `env:"WORKER_SECRET"`
 	ClientSecret string `env:"CLIENT_SECRET"`
 	HTTPPort     int    `env:"WORKER_PORT"`
+	DataPath     string `env:"WORKER_DATA_PATH"`
+	HistorySize  int    `env:"WORKER_HISTORY_SIZE"`
 }
 
 func NewConfig() (*Config, error) {
@@ -21,5 +23,12 @@ func NewConfig() (*Config, error) {
 		return nil, fmt.Errorf("read env error: %w", err)
 	}
 
+	if cfg.DataPath == "" {
+		cfg.DataPath = "./data/worker/config.json"
+	}
+	if cfg.HistorySize <= 0 {
+		cfg.HistorySize = 10
+	}
+
 	return &cfg, nil
 }
@@ -1,11 +1,16 @@
 package handler
 
 import (
+	"bytes"
 	"distributed-configuration/internal/worker/config"
 	"distributed-configuration/internal/worker/service"
 	"distributed-configuration/pkg/utils"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"go.uber.org/zap"
 )
@@ -31,9 +36,10 @@ func NewHandler(log *utils.Logger, cfg *config.Config, worker service.WorkerServ
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
-// @Param        request  body      map[string]interface{}  true  "config data"
-// @Success      200      {object}  map[string]interface{}
-// @Failure      400      {object}  map[string]string "Invalid request body"
+// @Param        X-Config-Version  header    string                  false  "Config version (ETag) the agent delivers"
+// @Param        request           body      map[string]interface{}  true   "config data"
+// @Success      200               {object}  map[string]interface{}
+// @Failure      400               {object}  map[string]string "Invalid request body"
 // @Router       /agent-config [post]
 func (h *handler) Save(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -49,7 +55,7 @@ func (h *handler) Save(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.worker.Save(r.Context(), payload)
+	err = h.worker.Save(r.Context(), r.Header.Get("X-Config-Version"), payload)
 	if err != nil {
 		h.log.Error("failed to register new agent", zap.Error(err))
 		status, msg := utils.MapError(err)
@@ -66,12 +72,18 @@ func (h *handler) Save(w http.ResponseWriter, r *http.Request) {
 
 // GetConfig godoc
 // @Summary      Fetch config data
-// @Description  Fetch config data for client
+// @Description  Fetch config data for client. The version is returned in the ETag header, send it back as If-None-Match to get 304 while it is unchanged. /hit?key=database.host&key=/cache/ttl returns only an object keyed by the requested keys (dotted paths or JSON Pointers), missing keys answer 404.
 // @Tags         client
 // @Produce      json
 // @Security     BearerAuth
-// @Success      200      		{object}  map[string]interface{}
+// @Param        key            query     []string  false  "Dotted path or JSON Pointer (repeatable)"  collectionFormat(multi)
+// @Param        type           query     string    false  "Convert values to string, int, float or bool"
+// @Param        If-None-Match  header    string    false  "Cached config version (ETag)"
+// @Success      200            {object}  map[string]interface{}
+// @Success      304            {string}  string "Not Modified"
+// @Failure      400            {object}  map[string]string "Value does not convert to type"
 // @Failure      401            {object}  map[string]string "Unauthorized"
+// @Failure      404            {object}  map[string]string "Key not found"
 // @Router       /hit [get]
 func (h handler) Get(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -79,7 +91,15 @@ func (h handler) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	res, err := h.worker.Get(r.Context())
+	if keys := r.URL.Query()["key"]; len(keys) > 0 {
+		h.lookup(w, r, keys, false)
+		return
+	}
+
+	res, etag, err := h.worker.Get(r.Context(), r.Header.Get("If-None-Match"))
+	if etag != "" {
+		w.Header().Set("ETag", strconv.Quote(etag))
+	}
 	if err != nil {
 		status, msg := utils.MapError(err)
 		if status == http.StatusNotModified {
@@ -93,3 +113,291 @@ func (h handler) Get(w http.ResponseWriter, r *http.Request) {
 
 	utils.WriteJSON(w, http.StatusOK, res)
 }
+
+// Lookup godoc
+// @Summary      Fetch config value
+// @Description  Fetch the single value at a JSON Pointer instead of the whole document, /hit/database/host returns the value of database.host. A missing key answers 404.
+// @Tags         client
+// @Produce      json
+// @Produce      plain
+// @Security     BearerAuth
+// @Param        path           path      string  true   "JSON Pointer below /hit"
+// @Param        type           query     string  false  "Convert the value to string, int, float or bool"
+// @Param        format         query     string  false  "text returns the value as plain text"
+// @Param        If-None-Match  header    string  false  "Cached config version (ETag)"
+// @Success      200            {object}  interface{}
+// @Success      304            {string}  string "Not Modified"
+// @Failure      400            {object}  map[string]string "Value does not convert to type"
+// @Failure      401            {object}  map[string]string "Unauthorized"
+// @Failure      404            {object}  map[string]string "Key not found"
+// @Router       /hit/{path} [get]
+func (h handler) Lookup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/hit")
+	if path == "" || path == "/" {
+		h.Get(w, r)
+		return
+	}
+	h.lookup(w, r, []string{path}, true)
+}
+
+// lookup writes the values at keys, the bare value for a single path.
+func (h handler) lookup(w http.ResponseWriter, r *http.Request, keys []string, single bool) {
+	typ := r.URL.Query().Get("type")
+	switch typ {
+	case "", "string", "int", "float", "bool":
+	default:
+		http.Error(w, "invalid type", http.StatusBadRequest)
+		return
+	}
+
+	res, etag, err := h.worker.Lookup(r.Context(), r.Header.Get("If-None-Match"), keys, typ)
+	if etag != "" {
+		w.Header().Set("ETag", strconv.Quote(etag))
+	}
+	if err != nil {
+		status, msg := utils.MapError(err)
+		if status == http.StatusNotModified {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		h.log.Error("failed to get config", zap.Error(err))
+		http.Error(w, msg, status)
+		return
+	}
+
+	var missing []string
+	for _, key := range keys {
+		if _, ok := res[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		http.Error(w, "key not found: "+strings.Join(missing, ", "), http.StatusNotFound)
+		return
+	}
+
+	if !single {
+		utils.WriteJSON(w, http.StatusOK, res)
+		return
+	}
+
+	value := res[keys[0]]
+	if r.URL.Query().Get("format") == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		switch v := value.(type) {
+		case string:
+			fmt.Fprintln(w, v)
+		case map[string]any, []any:
+			json.NewEncoder(w).Encode(v)
+		case nil:
+			fmt.Fprintln(w)
+		default:
+			fmt.Fprintln(w, v)
+		}
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, value)
+}
+
+// Version godoc
+// @Summary      Served config version
+// @Description  Revision, hash and receive time of the configuration this worker serves
+// @Tags         client
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200      {object}  model.ConfigRecord
+// @Failure      404      {object}  map[string]string "No configuration yet"
+// @Router       /version [get]
+func (h handler) Version(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	res, err := h.worker.Version(r.Context())
+	if err != nil {
+		status, msg := utils.MapError(err)
+		http.Error(w, msg, status)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, res)
+}
+
+// History godoc
+// @Summary      Config history
+// @Description  The last versions this worker received, newest first. With revision the full config of that version is returned.
+// @Tags         client
+// @Produce      json
+// @Security     BearerAuth
+// @Param        revision  query     int  false  "Revision to return in full"
+// @Success      200       {object}  map[string]interface{}
+// @Failure      404       {object}  map[string]string "Revision not found"
+// @Router       /history [get]
+func (h handler) History(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if v := r.URL.Query().Get("revision"); v != "" {
+		revision, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "invalid revision", http.StatusBadRequest)
+			return
+		}
+
+		res, err := h.worker.Revision(r.Context(), revision)
+		if err != nil {
+			status, msg := utils.MapError(err)
+			http.Error(w, msg, status)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, res)
+		return
+	}
+
+	resp := map[string]any{
+		"versions": h.worker.History(r.Context()),
+	}
+	utils.WriteJSON(w, http.StatusOK, resp)
+}
+
+// Watch godoc
+// @Summary      Watch config changes
+// @Description  Long-poll that returns the config as soon as its version differs from If-None-Match, or 304 once timeout passes without a change.
+// @Description  With Accept: text/event-stream (or stream=sse) every new version is streamed as a server-sent event named config, its id is the version.
+// @Tags         client
+// @Produce      json
+// @Produce      text/event-stream
+// @Security     BearerAuth
+// @Param        If-None-Match  header    string  false  "Cached config version (ETag)"
+// @Param        timeout        query     string  false  "Long-poll timeout, e.g. 30s (default 60s, at most 5m)"
+// @Param        stream         query     string  false  "sse to stream changes"
+// @Success      200            {object}  map[string]interface{}
+// @Success      304            {string}  string "Not Modified"
+// @Router       /watch [get]
+func (h handler) Watch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if r.URL.Query().Get("stream") == "sse" || strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		h.stream(w, r)
+		return
+	}
+
+	timeout := 60 * time.Second
+	if v := r.URL.Query().Get("timeout"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 || d > 5*time.Minute {
+			http.Error(w, "invalid timeout", http.StatusBadRequest)
+			return
+		}
+		timeout = d
+	}
+
+	ctx := r.Context()
+	etag := r.Header.Get("If-None-Match")
+
+	sendLatestConfig := func() bool {
+		record, err := h.worker.Current(ctx)
+		if err != nil {
+			return false
+		}
+
+		w.Header().Set("ETag", strconv.Quote(record.Tag()))
+		if utils.MatchETag(etag, record.Tag()) {
+			return false
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(record.Config)
+		return true
+	}
+
+	// subscribe before the first check so a push landing in between still
+	// wakes this request.
+	updateCh := h.worker.Subscribe()
+	defer h.worker.Unsubscribe(updateCh)
+
+	if sent := sendLatestConfig(); sent {
+		return
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			w.WriteHeader(http.StatusNotModified)
+			return
+		case <-updateCh:
+			if sent := sendLatestConfig(); sent {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// stream sends the config as server-sent events, first the current version
+// unless the client already has it (Last-Event-ID or If-None-Match), then
+// every new one.
+func (h handler) stream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	ctx := r.Context()
+	last := r.Header.Get("Last-Event-ID")
+	if last == "" {
+		last = r.Header.Get("If-None-Match")
+	}
+
+	updateCh := h.worker.Subscribe()
+	defer h.worker.Unsubscribe(updateCh)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+
+	for {
+		record, err := h.worker.Current(ctx)
+		if err == nil && !utils.MatchETag(last, record.Tag()) {
+			var data bytes.Buffer
+			json.Compact(&data, record.Config)
+			fmt.Fprintf(w, "id: %s\nevent: config\ndata: %s\n\n", record.Tag(), data.Bytes())
+			flusher.Flush()
+			last = record.Tag()
+		}
+
+		select {
+		case <-updateCh:
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
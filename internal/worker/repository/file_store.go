@@ -0,0 +1,52 @@
+package repository
+
+import (
+	model "distributed-configuration/pkg/models"
+	"distributed-configuration/pkg/utils"
+	"encoding/json"
+	"os"
+
+	"go.uber.org/zap"
+)
+
+// FileStore keeps the configuration a worker serves, and its history, on
+// disk so it survives restarts.
+type FileStore struct {
+	log      *utils.Logger
+	filepath string
+}
+
+func NewFileStore(log *utils.Logger, filepath string) *FileStore {
+	return &FileStore{log: log, filepath: filepath}
+}
+
+func (r *FileStore) Save(data *model.DataConfig) error {
+	content, err := json.MarshalIndent(data, "", " ")
+	if err != nil {
+		r.log.Error("failed encode worker config", zap.Error(err))
+		return err
+	}
+
+	err = utils.WriteFileAtomic(r.filepath, content, 0644)
+	if err != nil {
+		r.log.Error("failed write worker config", zap.String("path", r.filepath), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *FileStore) Load(data *model.DataConfig) error {
+	content, err := os.ReadFile(r.filepath)
+	if err != nil {
+		return err
+	}
+
+	err = json.Unmarshal(content, data)
+	if err != nil {
+		r.log.Error("failed parse worker config", zap.String("path", r.filepath), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"distributed-configuration/pkg/utils"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"go.uber.org/zap"
+)
+
+// Lookup returns the values at keys from the served config. A key is a JSON
+// Pointer (/database/host) or a dotted path (database.host), keys that do
+// not exist are left out of the result. With typ (string, int, float or
+// bool) every value is converted to that type. Like Get it returns
+// ErrNotModified when etag still matches the served version.
+func (s *workerService) Lookup(ctx context.Context, etag string, keys []string, typ string) (map[string]any, string, error) {
+	config, current := s.data.GetConfig()
+	if config == nil {
+		s.log.Error("no configuration active")
+		return nil, "", utils.ErrNotFound
+	}
+
+	if utils.MatchETag(etag, current) {
+		return nil, current, utils.ErrNotModified
+	}
+
+	doc, err := utils.DecodeJSON(config)
+	if err != nil {
+		s.log.Error("failed decode config", zap.Error(err))
+		return nil, current, utils.ErrInternal
+	}
+
+	res := make(map[string]any, len(keys))
+	for _, key := range keys {
+		value, ok, err := utils.Lookup(doc, utils.KeyPointer(key))
+		if err != nil {
+			return nil, current, utils.ErrInvalidInput
+		}
+		if !ok {
+			continue
+		}
+
+		if typ != "" {
+			value, err = convert(value, typ)
+			if err != nil {
+				s.log.Warn("failed convert config value", zap.String("key", key), zap.String("type", typ), zap.Error(err))
+				return nil, current, utils.ErrInvalidInput
+			}
+		}
+		res[key] = value
+	}
+
+	return res, current, nil
+}
+
+func convert(value any, typ string) (any, error) {
+	text := fmt.Sprint(value)
+	switch value.(type) {
+	case map[string]any, []any:
+		if typ != "string" {
+			return nil, fmt.Errorf("cannot convert %T to %s", value, typ)
+		}
+		data, err := json.Marshal(value)
+		return string(data), err
+	case nil:
+		text = ""
+	}
+
+	switch typ {
+	case "string":
+		return text, nil
+	case "int":
+		return strconv.ParseInt(text, 10, 64)
+	case "float":
+		return strconv.ParseFloat(text, 64)
+	case "bool":
+		return strconv.ParseBool(text)
+	default:
+		return nil, fmt.Errorf("unknown type %q", typ)
+	}
+}
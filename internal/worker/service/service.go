@@ -2,31 +2,83 @@ package service
 
 import (
 	"context"
+	"crypto/sha256"
+	"distributed-configuration/internal/worker/config"
+	"distributed-configuration/internal/worker/repository"
 	model "distributed-configuration/pkg/models"
 	"distributed-configuration/pkg/utils"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"os"
+	"sync"
 
 	"go.uber.org/zap"
 )
 
 type WorkerService interface {
-	Save(ctx context.Context, config json.RawMessage) error
-	Get(ctx context.Context) (map[string]any, error)
+	Save(ctx context.Context, etag string, config json.RawMessage) error
+	Get(ctx context.Context, etag string) (map[string]any, string, error)
+	Lookup(ctx context.Context, etag string, keys []string, typ string) (map[string]any, string, error)
+	Version(ctx context.Context) (model.ConfigRecord, error)
+	History(ctx context.Context) []model.ConfigRecord
+	Revision(ctx context.Context, revision int) (model.ConfigRecord, error)
+	Restore(ctx context.Context) error
+	Current(ctx context.Context) (model.ConfigRecord, error)
+	Subscribe() chan struct{}
+	Unsubscribe(ch chan struct{})
 }
 
 type workerService struct {
 	log  *utils.Logger
+	repo *repository.FileStore
+	cfg  *config.Config
 	data *model.DataConfig
+
+	// saveMu serializes pushes, so a failed save can only roll back its own
+	// update and a concurrent push never persists a half applied state.
+	saveMu sync.Mutex
+
+	watchers watchers
 }
 
-func NewWorkerService(log *utils.Logger) WorkerService {
+func NewWorkerService(log *utils.Logger, repo *repository.FileStore, cfg *config.Config) WorkerService {
 	return &workerService{
 		log:  log,
+		repo: repo,
+		cfg:  cfg,
 		data: &model.DataConfig{},
 	}
 }
 
-func (s *workerService) Save(ctx context.Context, config json.RawMessage) error {
+// Restore loads the last known good configuration persisted before a
+// restart, so clients are served right away instead of after the next push.
+func (s *workerService) Restore(ctx context.Context) error {
+	var data model.DataConfig
+	err := s.repo.Load(&data)
+	if errors.Is(err, os.ErrNotExist) {
+		s.log.Info("no persisted configuration, waiting for the agent")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	s.data.Restore(&data)
+	if version, ok := s.data.Version(); ok {
+		s.log.Info(
+			"restored last known good configuration",
+			zap.Int("revision", version.Revision),
+			zap.Time("updated_at", version.UpdatedAt),
+		)
+	}
+
+	return nil
+}
+
+// Save stores config pushed by an agent under the version etag it was sent
+// with.
+func (s *workerService) Save(ctx context.Context, etag string, config json.RawMessage) error {
 	if len(config) == 0 {
 		s.log.Error("config content cannot be empty")
 		return utils.ErrNotFound
@@ -37,21 +89,72 @@ func (s *workerService) Save(ctx context.Context, config json.RawMessage) error
 		return utils.ErrConflict
 	}
 
-	s.data.UpdateData(config)
-	s.log.Info("configuration successfully updated", zap.String("data", string(config)))
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+
+	sum := sha256.Sum256(config)
+	prev := s.data.Snapshot()
+	record, changed := s.data.UpdateData(config, etag, hex.EncodeToString(sum[:]), s.cfg.HistorySize)
+	if !changed {
+		s.log.Info("configuration already served", zap.Int("revision", record.Revision))
+		return nil
+	}
+
+	// an unpersisted config is rolled back so the agent retries the push
+	// instead of the worker losing it on the next restart.
+	err := s.repo.Save(s.data.Snapshot())
+	if err != nil {
+		s.data.Restore(prev)
+		return utils.ErrInternal
+	}
+
+	s.watchers.broadcast()
+	s.log.Info(
+		"configuration successfully updated",
+		zap.Int("revision", record.Revision),
+		zap.String("etag", etag),
+		zap.String("data", string(config)),
+	)
 
 	return nil
 }
-func (s *workerService) Get(ctx context.Context) (map[string]any, error) {
-	config := s.data.GetConfig()
+
+// Get returns the served config and its etag, or ErrNotModified when etag
+// (an If-None-Match value) already matches it.
+func (s *workerService) Get(ctx context.Context, etag string) (map[string]any, string, error) {
+	config, current := s.data.GetConfig()
 	if config == nil {
 		s.log.Error("no configuration active")
-		return nil, utils.ErrNotFound
+		return nil, "", utils.ErrNotFound
+	}
+
+	if utils.MatchETag(etag, current) {
+		return nil, current, utils.ErrNotModified
 	}
 
 	res := map[string]any{}
 	json.Unmarshal(config, &res)
 	s.log.Info("get config successfully", zap.Any("data", res))
 
-	return res, nil
+	return res, current, nil
+}
+
+func (s *workerService) Version(ctx context.Context) (model.ConfigRecord, error) {
+	version, ok := s.data.Version()
+	if !ok {
+		return model.ConfigRecord{}, utils.ErrNotFound
+	}
+	return version, nil
+}
+
+func (s *workerService) History(ctx context.Context) []model.ConfigRecord {
+	return s.data.Versions()
+}
+
+func (s *workerService) Revision(ctx context.Context, revision int) (model.ConfigRecord, error) {
+	record, ok := s.data.Revision(revision)
+	if !ok {
+		return model.ConfigRecord{}, utils.ErrNotFound
+	}
+	return record, nil
 }
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	model "distributed-configuration/pkg/models"
+	"distributed-configuration/pkg/utils"
+	"sync"
+)
+
+// watchers wakes the watch requests whenever Save stores a new version.
+// A wake-up only says something changed, watchers read the latest record
+// themselves, so a slow watcher skips versions instead of blocking Save.
+type watchers struct {
+	mu        sync.Mutex
+	listeners map[chan struct{}]struct{}
+}
+
+func (w *watchers) subscribe() chan struct{} {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.listeners == nil {
+		w.listeners = make(map[chan struct{}]struct{})
+	}
+
+	ch := make(chan struct{}, 1)
+	w.listeners[ch] = struct{}{}
+	return ch
+}
+
+func (w *watchers) unsubscribe(ch chan struct{}) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.listeners, ch)
+}
+
+func (w *watchers) broadcast() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for ch := range w.listeners {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Subscribe returns a channel signalled after every newly stored version.
+func (s *workerService) Subscribe() chan struct{} {
+	return s.watchers.subscribe()
+}
+
+func (s *workerService) Unsubscribe(ch chan struct{}) {
+	s.watchers.unsubscribe(ch)
+}
+
+// Current returns the served record including its config.
+func (s *workerService) Current(ctx context.Context) (model.ConfigRecord, error) {
+	record, ok := s.data.Latest()
+	if !ok {
+		return model.ConfigRecord{}, utils.ErrNotFound
+	}
+	return record, nil
+}
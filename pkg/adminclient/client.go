@@ -0,0 +1,320 @@
+// Package adminclient drives the controller admin API from Go, e.g. for
+// deployment tooling and tests.
+//
+//	client, err := adminclient.New(adminclient.Options{
+//		URL:    "http://localhost:8080",
+//		Secret: os.Getenv("ADMIN_SECRET"),
+//	})
+//	if err != nil {
+//		return err
+//	}
+//
+//	version, err := client.Save(ctx, json.RawMessage(`{"data":{"feature":true}}`))
+//	if errors.Is(err, utils.ErrNotModified) {
+//		// the controller already serves this configuration
+//	}
+//
+//	history, err := client.History(ctx)
+//	_, err = client.Rollback(ctx, history[1].Version)
+//
+// Failed requests return an *Error that wraps the utils error the controller
+// mapped to the response status, so callers match them with errors.Is.
+package adminclient
+
+import (
+	"bytes"
+	"context"
+	model "distributed-configuration/pkg/models"
+	"distributed-configuration/pkg/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Options struct {
+	// URL is the controller base URL, e.g. http://localhost:8080.
+	URL string
+	// Secret is the controller ADMIN_SECRET.
+	Secret string
+	// HTTPClient defaults to a client with a 30s timeout.
+	HTTPClient *http.Client
+	// Retries is how often a request is repeated after a connection error or
+	// an unavailable controller (502, 503, 504), 3 by default, negative
+	// disables retries. POST and DELETE, which change state, are repeated
+	// after a connection error only when the connection was never made.
+	// Timeouts are not retried.
+	Retries int
+	// RetryWait is the wait before the first retry, doubled on every next
+	// one, 500ms by default.
+	RetryWait time.Duration
+}
+
+type Client struct {
+	opts Options
+}
+
+// Error is a request the controller answered with an error status.
+type Error struct {
+	StatusCode int
+	Message    string
+	Err        error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("controller: %s (status %d)", e.Message, e.StatusCode)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func New(opts Options) (*Client, error) {
+	if opts.URL == "" {
+		return nil, errors.New("adminclient: missing controller url")
+	}
+
+	opts.URL = strings.TrimRight(opts.URL, "/")
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	if opts.Retries == 0 {
+		opts.Retries = 3
+	}
+	if opts.RetryWait <= 0 {
+		opts.RetryWait = 500 * time.Millisecond
+	}
+
+	return &Client{opts: opts}, nil
+}
+
+// Save stores data as a new configuration version and returns it. It fails
+// with utils.ErrNotModified when data equals the latest version.
+func (c *Client) Save(ctx context.Context, data json.RawMessage) (int, error) {
+	var resp struct {
+		Version int `json:"version"`
+	}
+	err := c.do(ctx, http.MethodPost, "/admin/config", nil, nil, data, &resp)
+	if err != nil {
+		return 0, err
+	}
+
+	return resp.Version, nil
+}
+
+// SaveIfLatest is Save that only succeeds while version, e.g. read with Get,
+// is still the latest, 0 when no version was saved yet. Otherwise it fails
+// with utils.ErrPreconditionFailed, or utils.ErrConflict when another
+// version was saved at the same moment.
+func (c *Client) SaveIfLatest(ctx context.Context, data json.RawMessage, version int) (int, error) {
+	header := http.Header{"If-Match": {`"` + utils.FormatVersion(version) + `"`}}
+
+	var resp struct {
+		Version int `json:"version"`
+	}
+	err := c.do(ctx, http.MethodPost, "/admin/config", nil, header, data, &resp)
+	if err != nil {
+		return 0, err
+	}
+
+	return resp.Version, nil
+}
+
+// Get returns a configuration version with its data, the latest one for 0.
+func (c *Client) Get(ctx context.Context, version int) (model.ConfigVersion, error) {
+	query := url.Values{}
+	if version > 0 {
+		query.Set("version", strconv.Itoa(version))
+	}
+
+	var config model.ConfigVersion
+	err := c.do(ctx, http.MethodGet, "/admin/config", query, nil, nil, &config)
+	return config, err
+}
+
+// History lists every stored version without its data, newest first.
+func (c *Client) History(ctx context.Context) ([]model.ConfigVersion, error) {
+	var versions []model.ConfigVersion
+	err := c.do(ctx, http.MethodGet, "/admin/config/history", nil, nil, nil, &versions)
+	return versions, err
+}
+
+// Rollback saves the data of version as a new version and returns it. It
+// fails with utils.ErrNotModified when that data is already the latest.
+func (c *Client) Rollback(ctx context.Context, version int) (int, error) {
+	query := url.Values{"version": {strconv.Itoa(version)}}
+
+	var resp struct {
+		Version int `json:"version"`
+	}
+	err := c.do(ctx, http.MethodPost, "/admin/config/rollback", query, nil, nil, &resp)
+	if err != nil {
+		return 0, err
+	}
+
+	return resp.Version, nil
+}
+
+// Pin keeps a version from being pruned, or releases it again.
+func (c *Client) Pin(ctx context.Context, version int, pinned bool) error {
+	method := http.MethodPost
+	if !pinned {
+		method = http.MethodDelete
+	}
+
+	query := url.Values{"version": {strconv.Itoa(version)}}
+	return c.do(ctx, method, "/admin/config/pin", query, nil, nil, nil)
+}
+
+// Prune applies the retention policy and returns the removed versions, with
+// dryRun only the ones that would be removed.
+func (c *Client) Prune(ctx context.Context, dryRun bool) ([]int, error) {
+	query := url.Values{"dry_run": {strconv.FormatBool(dryRun)}}
+
+	var resp struct {
+		Versions []int `json:"versions"`
+	}
+	err := c.do(ctx, http.MethodPost, "/admin/config/prune", query, nil, nil, &resp)
+	return resp.Versions, err
+}
+
+// Agents lists the registered agents.
+func (c *Client) Agents(ctx context.Context) ([]model.Agent, error) {
+	var agents []model.Agent
+	err := c.do(ctx, http.MethodGet, "/admin/agents", nil, nil, nil, &agents)
+	return agents, err
+}
+
+// RemoveAgent deregisters an agent, it registers again on its next poll if
+// it is still running.
+func (c *Client) RemoveAgent(ctx context.Context, agentID string) error {
+	query := url.Values{"agent_id": {agentID}}
+	return c.do(ctx, http.MethodDelete, "/admin/agents", query, nil, nil, nil)
+}
+
+// do sends the request, retrying connection errors and unavailable
+// controllers, and decodes a successful JSON response into out.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, header http.Header, body []byte, out any) error {
+	target := c.opts.URL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	wait := c.opts.RetryWait
+	for attempt := 0; ; attempt++ {
+		err := c.send(ctx, method, target, header, body, out)
+		if err == nil || ctx.Err() != nil || !retryable(method, err) || attempt >= c.opts.Retries {
+			return err
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return err
+		}
+		wait *= 2
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, target string, header http.Header, body []byte, out any) error {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return err
+	}
+
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Authorization", "Bearer "+c.opts.Secret)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.opts.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		msg, _ := io.ReadAll(resp.Body)
+		return newError(resp.StatusCode, resp.Header, strings.TrimSpace(string(msg)))
+	}
+
+	if out == nil {
+		return nil
+	}
+	err = json.NewDecoder(resp.Body).Decode(out)
+	if err != nil {
+		return fmt.Errorf("adminclient: decode response: %w", err)
+	}
+	return nil
+}
+
+// retryable reports whether a failed request is safe and worth repeating.
+// The controller may have applied a POST or DELETE whose response got lost,
+// so those are only repeated when the connection was never made or a proxy
+// reported the controller unavailable.
+func retryable(method string, err error) bool {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusBadGateway ||
+			apiErr.StatusCode == http.StatusServiceUnavailable ||
+			apiErr.StatusCode == http.StatusGatewayTimeout
+	}
+
+	// anything else than a transport error, e.g. an undecodable response,
+	// fails the same way again.
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) || urlErr.Timeout() {
+		return false
+	}
+
+	if method == http.MethodGet {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// newError maps the status back to the utils error the controller derived it
+// from with utils.MapError.
+func newError(status int, header http.Header, msg string) *Error {
+	var err error
+	switch status {
+	case http.StatusNotFound:
+		err = utils.ErrNotFound
+		if header.Get("X-Agent-Unknown") == "true" {
+			err = utils.ErrUnknownAgent
+		}
+	case http.StatusBadRequest:
+		err = utils.ErrInvalidInput
+	case http.StatusUnauthorized, http.StatusForbidden:
+		err = utils.ErrUnauthorized
+	case http.StatusConflict:
+		err = utils.ErrConflict
+	case http.StatusNotModified:
+		err = utils.ErrNotModified
+	case http.StatusPreconditionFailed:
+		err = utils.ErrPreconditionFailed
+	default:
+		err = utils.ErrInternal
+	}
+
+	if msg == "" {
+		msg = err.Error()
+	}
+	return &Error{StatusCode: status, Message: msg, Err: err}
+}
@@ -0,0 +1,246 @@
+package adminclient
+
+import (
+	"context"
+	"distributed-configuration/pkg/utils"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestClient(t *testing.T, url string, httpClient *http.Client) *Client {
+	t.Helper()
+
+	client, err := New(Options{
+		URL:        url,
+		Secret:     "admin-secret",
+		HTTPClient: httpClient,
+		Retries:    2,
+		RetryWait:  time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	return client
+}
+
+func TestErrorMapping(t *testing.T) {
+	tests := []struct {
+		status  int
+		message string
+		header  string
+		want    error
+	}{
+		{http.StatusNotFound, "resource not found", "", utils.ErrNotFound},
+		{http.StatusNotFound, "unknown agent", "", utils.ErrNotFound},
+		{http.StatusNotFound, "unknown agent", "true", utils.ErrUnknownAgent},
+		{http.StatusBadRequest, "invalid version", "", utils.ErrInvalidInput},
+		{http.StatusUnauthorized, "unauthorized", "", utils.ErrUnauthorized},
+		{http.StatusForbidden, "forbidden", "", utils.ErrUnauthorized},
+		{http.StatusConflict, "resource conflict", "", utils.ErrConflict},
+		{http.StatusNotModified, "", "", utils.ErrNotModified},
+		{http.StatusPreconditionFailed, "version precondition failed", "", utils.ErrPreconditionFailed},
+		{http.StatusInternalServerError, "internal server error", "", utils.ErrInternal},
+		{http.StatusTeapot, "teapot", "", utils.ErrInternal},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status)+"/"+tt.message+"/"+tt.header, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") != "Bearer admin-secret" {
+					t.Errorf("authorization = %q", r.Header.Get("Authorization"))
+				}
+				if tt.header != "" {
+					w.Header().Set("X-Agent-Unknown", tt.header)
+				}
+				if tt.message == "" {
+					w.WriteHeader(tt.status)
+					return
+				}
+				http.Error(w, tt.message, tt.status)
+			}))
+			defer srv.Close()
+
+			_, err := newTestClient(t, srv.URL, nil).Get(context.Background(), 1)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+
+			var apiErr *Error
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("err = %T, want *Error", err)
+			}
+			if apiErr.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d", apiErr.StatusCode, tt.status)
+			}
+			wantMessage := tt.message
+			if wantMessage == "" {
+				wantMessage = tt.want.Error()
+			}
+			if apiErr.Message != wantMessage {
+				t.Fatalf("message = %q, want %q", apiErr.Message, wantMessage)
+			}
+		})
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	unavailable := func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}
+	failing := func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+	}
+	hangUp := func(w http.ResponseWriter, r *http.Request) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	}
+	garbage := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("not json"))
+	}
+	slow := func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		handler  http.HandlerFunc
+		timeout  time.Duration
+		attempts int32
+	}{
+		{"GET unavailable", http.MethodGet, unavailable, 0, 3},
+		{"POST unavailable", http.MethodPost, unavailable, 0, 3},
+		{"DELETE unavailable", http.MethodDelete, unavailable, 0, 3},
+		{"POST internal error", http.MethodPost, failing, 0, 1},
+		{"GET connection lost", http.MethodGet, hangUp, 0, 3},
+		{"POST connection lost", http.MethodPost, hangUp, 0, 1},
+		{"DELETE connection lost", http.MethodDelete, hangUp, 0, 1},
+		{"GET decode error", http.MethodGet, garbage, 0, 1},
+		{"GET timeout", http.MethodGet, slow, 50 * time.Millisecond, 1},
+		{"POST timeout", http.MethodPost, slow, 50 * time.Millisecond, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts.Add(1)
+				tt.handler(w, r)
+			}))
+			defer srv.Close()
+
+			httpClient := &http.Client{Timeout: tt.timeout}
+			client := newTestClient(t, srv.URL, httpClient)
+			err := client.do(context.Background(), tt.method, "/admin/config", nil, nil, nil, &struct{}{})
+			if err == nil {
+				t.Fatal("request succeeded, want an error")
+			}
+			if got := attempts.Load(); got != tt.attempts {
+				t.Fatalf("attempts = %d, want %d (err %v)", got, tt.attempts, err)
+			}
+		})
+	}
+}
+
+func TestRetryRecovers(t *testing.T) {
+	var attempts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			http.Error(w, "bad gateway", http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"message":"configuration saved successfully","version":4}`))
+	}))
+	defer srv.Close()
+
+	version, err := newTestClient(t, srv.URL, nil).Save(context.Background(), []byte(`{"data":{}}`))
+	if err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if version != 4 || attempts.Load() != 2 {
+		t.Fatalf("version = %d after %d attempts, want 4 after 2", version, attempts.Load())
+	}
+}
+
+// countingTransport counts the requests that reached the transport, also
+// those that never got a connection.
+type countingTransport struct {
+	calls atomic.Int32
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.calls.Add(1)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestRetryConnectionRefused(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+
+	for _, method := range []string{http.MethodGet, http.MethodPost, http.MethodDelete} {
+		t.Run(method, func(t *testing.T) {
+			transport := &countingTransport{}
+			client := newTestClient(t, url, &http.Client{Transport: transport})
+
+			err := client.do(context.Background(), method, "/admin/config", nil, nil, nil, nil)
+			if err == nil {
+				t.Fatal("request succeeded, want an error")
+			}
+			if got := transport.calls.Load(); got != 3 {
+				t.Fatalf("attempts = %d, want 3 (err %v)", got, err)
+			}
+		})
+	}
+}
+
+func TestRetryStopsWithContext(t *testing.T) {
+	var attempts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	client, err := New(Options{URL: srv.URL, Retries: 5, RetryWait: time.Hour})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err = client.History(ctx)
+	if !errors.Is(err, utils.ErrInternal) {
+		t.Fatalf("err = %v, want the last response error", err)
+	}
+	if attempts.Load() != 1 {
+		t.Fatalf("attempts = %d, want 1", attempts.Load())
+	}
+}
+
+func TestSaveIfLatest(t *testing.T) {
+	var ifMatch string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ifMatch = r.Header.Get("If-Match")
+		http.Error(w, "version precondition failed", http.StatusPreconditionFailed)
+	}))
+	defer srv.Close()
+
+	_, err := newTestClient(t, srv.URL, nil).SaveIfLatest(context.Background(), []byte(`{"data":{}}`), 3)
+	if !errors.Is(err, utils.ErrPreconditionFailed) {
+		t.Fatalf("err = %v, want ErrPreconditionFailed", err)
+	}
+	if ifMatch != `"v3"` {
+		t.Fatalf("If-Match = %q, want %q", ifMatch, `"v3"`)
+	}
+}
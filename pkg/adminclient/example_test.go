@@ -0,0 +1,104 @@
+package adminclient_test
+
+import (
+	"context"
+	"distributed-configuration/pkg/adminclient"
+	"distributed-configuration/pkg/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+)
+
+func Example() {
+	client, err := adminclient.New(adminclient.Options{
+		URL:    "http://localhost:8080",
+		Secret: os.Getenv("ADMIN_SECRET"),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	version, err := client.Save(context.Background(), json.RawMessage(`{"data":{"feature":true}}`))
+	if errors.Is(err, utils.ErrNotModified) {
+		fmt.Println("the controller already serves this configuration")
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("saved version", version)
+}
+
+func ExampleClient_Rollback() {
+	client, err := adminclient.New(adminclient.Options{
+		URL:    "http://localhost:8080",
+		Secret: os.Getenv("ADMIN_SECRET"),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx := context.Background()
+	history, err := client.History(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(history) < 2 {
+		return
+	}
+
+	// history is newest first, go back to the version before the latest.
+	version, err := client.Rollback(ctx, history[1].Version)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("restored version %d as version %d\n", history[1].Version, version)
+}
+
+func ExampleClient_RemoveAgent() {
+	client, err := adminclient.New(adminclient.Options{
+		URL:    "http://localhost:8080",
+		Secret: os.Getenv("ADMIN_SECRET"),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx := context.Background()
+	agents, err := client.Agents(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, agent := range agents {
+		if agent.Host != "decommissioned-host" {
+			continue
+		}
+		err = client.RemoveAgent(ctx, agent.Id)
+		if err != nil && !errors.Is(err, utils.ErrNotFound) {
+			log.Fatal(err)
+		}
+	}
+}
+
+func ExampleError() {
+	client, err := adminclient.New(adminclient.Options{
+		URL:    "http://localhost:8080",
+		Secret: os.Getenv("ADMIN_SECRET"),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = client.Get(context.Background(), 42)
+	var apiErr *adminclient.Error
+	switch {
+	case errors.Is(err, utils.ErrNotFound):
+		fmt.Println("version 42 does not exist")
+	case errors.As(err, &apiErr):
+		fmt.Println("controller answered", apiErr.StatusCode, apiErr.Message)
+	case err != nil:
+		fmt.Println("controller unreachable:", err)
+	}
+}
@@ -0,0 +1,295 @@
+// Package configclient reads configuration from a worker for applications.
+//
+// A Client keeps a snapshot of the served config fresh in the background by
+// long-polling the worker's /watch endpoint, binds it into structs, calls
+// back when watched keys change and falls back to a local file while the
+// worker is unreachable.
+//
+//	client, err := configclient.New(configclient.Options{
+//		URL:          "http://localhost:8181",
+//		Secret:       os.Getenv("CLIENT_SECRET"),
+//		FallbackFile: "/var/lib/app/config.json",
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	err = client.Start(ctx)
+//	defer client.Close()
+//
+//	var cfg AppConfig
+//	err = client.Bind(&cfg)
+//	client.OnChange("database.host", func(old, new any) { ... })
+package configclient
+
+import (
+	"context"
+	"distributed-configuration/pkg/utils"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxWatchTimeout is the longest long-poll the worker accepts on /watch.
+const maxWatchTimeout = 5 * time.Minute
+
+// ErrNoConfig is returned by Start when neither the worker nor the fallback
+// file has a configuration.
+var ErrNoConfig = errors.New("configclient: no configuration available")
+
+type Options struct {
+	// URL is the worker base URL, e.g. http://localhost:8181.
+	URL string
+	// Secret is the worker CLIENT_SECRET.
+	Secret string
+	// FallbackFile keeps the last received config on disk. It is read when
+	// the worker cannot be reached on Start and rewritten on every change.
+	FallbackFile string
+	// WatchTimeout bounds one long-poll on /watch, 60s by default and at
+	// most 5m, the longest the worker accepts.
+	WatchTimeout time.Duration
+	// HTTPClient defaults to http.DefaultClient, requests are bounded by
+	// WatchTimeout.
+	HTTPClient *http.Client
+	// OnError receives background refresh errors, e.g. to log them.
+	OnError func(error)
+}
+
+type Client struct {
+	opts Options
+
+	mu       sync.RWMutex
+	snapshot Snapshot
+	watchers map[string][]func(old, new any)
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func New(opts Options) (*Client, error) {
+	if opts.URL == "" {
+		return nil, errors.New("configclient: missing worker url")
+	}
+
+	opts.URL = strings.TrimRight(opts.URL, "/")
+	if opts.WatchTimeout <= 0 {
+		opts.WatchTimeout = 60 * time.Second
+	}
+	if opts.WatchTimeout > maxWatchTimeout {
+		return nil, fmt.Errorf("configclient: watch timeout %s exceeds %s", opts.WatchTimeout, maxWatchTimeout)
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = http.DefaultClient
+	}
+
+	return &Client{
+		opts:     opts,
+		watchers: make(map[string][]func(old, new any)),
+	}, nil
+}
+
+// Start loads the config from the worker, or from the fallback file when
+// the worker cannot be reached, and keeps it fresh in the background until
+// Close. It returns ErrNoConfig when neither has one.
+func (c *Client) Start(ctx context.Context) error {
+	err := c.poll(ctx, "", time.Second)
+	if err != nil {
+		c.report(err)
+	}
+
+	if c.Snapshot().Data == nil && c.opts.FallbackFile != "" {
+		data, readErr := os.ReadFile(c.opts.FallbackFile)
+		if readErr == nil {
+			err = c.update("", data, false)
+		}
+	}
+	if c.Snapshot().Data == nil {
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrNoConfig, err)
+		}
+		return ErrNoConfig
+	}
+
+	runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	c.cancel = cancel
+	c.done = make(chan struct{})
+	go c.run(runCtx)
+
+	return nil
+}
+
+// Close stops the background refresh.
+func (c *Client) Close() {
+	if c.cancel == nil {
+		return
+	}
+
+	c.cancel()
+	<-c.done
+}
+
+// Snapshot returns the current configuration.
+func (c *Client) Snapshot() Snapshot {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.snapshot
+}
+
+// Bind decodes the current configuration into v, usually a pointer to a
+// struct with json tags.
+func (c *Client) Bind(v any) error {
+	return c.Snapshot().Bind(v)
+}
+
+// Get returns the value at key, a dotted path (database.host) or a JSON
+// Pointer (/database/host).
+func (c *Client) Get(key string) (any, bool) {
+	return c.Snapshot().Get(key)
+}
+
+func (c *Client) String(key, def string) string {
+	return c.Snapshot().String(key, def)
+}
+
+func (c *Client) Int(key string, def int64) int64 {
+	return c.Snapshot().Int(key, def)
+}
+
+func (c *Client) Float(key string, def float64) float64 {
+	return c.Snapshot().Float(key, def)
+}
+
+func (c *Client) Bool(key string, def bool) bool {
+	return c.Snapshot().Bool(key, def)
+}
+
+// OnChange calls fn with the old and new value whenever the value at key
+// changes, an empty key watches the whole document. fn runs on the refresh
+// goroutine, a missing value is passed as nil.
+func (c *Client) OnChange(key string, fn func(old, new any)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.watchers[key] = append(c.watchers[key], fn)
+}
+
+func (c *Client) run(ctx context.Context) {
+	defer close(c.done)
+
+	backoff := time.Second
+	for {
+		err := c.poll(ctx, c.Snapshot().ETag, c.opts.WatchTimeout)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			backoff = time.Second
+			continue
+		}
+
+		// the last snapshot keeps being served while the worker is away.
+		c.report(err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+		backoff = min(backoff*2, 30*time.Second)
+	}
+}
+
+// poll waits up to timeout on the worker for a version other than etag and
+// applies it.
+func (c *Client) poll(ctx context.Context, etag string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout+10*time.Second)
+	defer cancel()
+
+	url := c.opts.URL + "/watch?timeout=" + timeout.String()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.opts.Secret)
+	req.Header.Set("Accept", "application/json")
+	if etag != "" {
+		req.Header.Set("If-None-Match", strconv.Quote(etag))
+	}
+
+	resp, err := c.opts.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return nil
+	case http.StatusOK:
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		return c.update(unquote(resp.Header.Get("ETag")), data, true)
+	default:
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("configclient: watch failed (status %d): %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+}
+
+// update swaps in a new snapshot, persists it to the fallback file and runs
+// the callbacks of the keys that changed.
+func (c *Client) update(etag string, data []byte, persist bool) error {
+	next, err := newSnapshot(etag, data)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	prev := c.snapshot
+	c.snapshot = next
+	watchers := make(map[string][]func(old, new any), len(c.watchers))
+	for key, fns := range c.watchers {
+		watchers[key] = slices.Clone(fns)
+	}
+	c.mu.Unlock()
+
+	if persist && c.opts.FallbackFile != "" {
+		err = utils.WriteFileAtomic(c.opts.FallbackFile, data, 0644)
+		if err != nil {
+			c.report(fmt.Errorf("configclient: write fallback file: %w", err))
+		}
+	}
+
+	if prev.Data == nil {
+		return nil
+	}
+	for key, fns := range watchers {
+		old, _ := prev.Get(key)
+		new, _ := next.Get(key)
+		if reflect.DeepEqual(old, new) {
+			continue
+		}
+		for _, fn := range fns {
+			fn(old, new)
+		}
+	}
+
+	return nil
+}
+
+func (c *Client) report(err error) {
+	if c.opts.OnError != nil {
+		c.opts.OnError(err)
+	}
+}
+
+func unquote(etag string) string {
+	return strings.Trim(strings.TrimPrefix(etag, "W/"), `"`)
+}
@@ -0,0 +1,325 @@
+package configclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeWorker serves /watch like the worker: 200 with the current version when
+// If-None-Match differs, otherwise 304 once the version changes or after a
+// short wait.
+type fakeWorker struct {
+	t *testing.T
+
+	mu      sync.Mutex
+	etag    string
+	data    string
+	changed chan struct{}
+	notMod  int
+	ifNone  []string
+}
+
+func newFakeWorker(t *testing.T, etag, data string) (*fakeWorker, *httptest.Server) {
+	w := &fakeWorker{t: t, etag: etag, data: data, changed: make(chan struct{})}
+	srv := httptest.NewServer(w)
+	t.Cleanup(srv.Close)
+	return w, srv
+}
+
+func (f *fakeWorker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/watch" || r.Header.Get("Authorization") != "Bearer client-secret" {
+		f.t.Errorf("request %s with authorization %q", r.URL.Path, r.Header.Get("Authorization"))
+	}
+
+	f.mu.Lock()
+	ifNone := r.Header.Get("If-None-Match")
+	f.ifNone = append(f.ifNone, ifNone)
+	changed := f.changed
+	unchanged := ifNone == strconv.Quote(f.etag)
+	f.mu.Unlock()
+
+	if unchanged {
+		select {
+		case <-changed:
+		case <-time.After(20 * time.Millisecond):
+			f.mu.Lock()
+			f.notMod++
+			f.mu.Unlock()
+			w.WriteHeader(http.StatusNotModified)
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w.Header().Set("ETag", strconv.Quote(f.etag))
+	w.Write([]byte(f.data))
+}
+
+func (f *fakeWorker) publish(etag, data string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.etag, f.data = etag, data
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+func (f *fakeWorker) stats() (notModified int, ifNoneMatch []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.notMod, append([]string(nil), f.ifNone...)
+}
+
+func newTestClient(t *testing.T, opts Options) *Client {
+	t.Helper()
+
+	opts.Secret = "client-secret"
+	opts.WatchTimeout = time.Second
+	opts.OnError = func(err error) {}
+	client, err := New(opts)
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	t.Cleanup(client.Close)
+	return client
+}
+
+// waitFor polls cond until it holds or a second passed.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestStartFallsBackToFile(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+
+	fallback := filepath.Join(t.TempDir(), "config.json")
+	err := os.WriteFile(fallback, []byte(`{"database":{"host":"cached"}}`), 0644)
+	if err != nil {
+		t.Fatalf("write fallback: %v", err)
+	}
+
+	client := newTestClient(t, Options{URL: url, FallbackFile: fallback})
+	err = client.Start(context.Background())
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if got := client.String("database.host", ""); got != "cached" {
+		t.Fatalf("database.host = %q, want cached", got)
+	}
+	if etag := client.Snapshot().ETag; etag != "" {
+		t.Fatalf("etag = %q, want none for the fallback file", etag)
+	}
+}
+
+func TestStartWithoutConfig(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+
+	client := newTestClient(t, Options{URL: url, FallbackFile: filepath.Join(t.TempDir(), "missing.json")})
+	err := client.Start(context.Background())
+	if !errors.Is(err, ErrNoConfig) {
+		t.Fatalf("err = %v, want ErrNoConfig", err)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	worker, srv := newFakeWorker(t, "v1", `{"pool":{"workers":4}}`)
+	fallback := filepath.Join(t.TempDir(), "config.json")
+
+	client := newTestClient(t, Options{URL: srv.URL, FallbackFile: fallback})
+	err := client.Start(context.Background())
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if etag := client.Snapshot().ETag; etag != "v1" {
+		t.Fatalf("etag = %q, want v1", etag)
+	}
+
+	// 304s keep the current snapshot.
+	waitFor(t, "a 304", func() bool {
+		notModified, _ := worker.stats()
+		return notModified >= 2
+	})
+	if got := client.Int("pool.workers", 0); got != 4 {
+		t.Fatalf("pool.workers = %d after 304, want 4", got)
+	}
+
+	worker.publish("v2", `{"pool":{"workers":8}}`)
+	waitFor(t, "v2", func() bool { return client.Snapshot().ETag == "v2" })
+	if got := client.Int("pool.workers", 0); got != 8 {
+		t.Fatalf("pool.workers = %d, want 8", got)
+	}
+	client.Close()
+
+	_, ifNoneMatch := worker.stats()
+	if ifNoneMatch[0] != "" || ifNoneMatch[1] != `"v1"` {
+		t.Fatalf("If-None-Match = %q, want none and then \"v1\"", ifNoneMatch[:2])
+	}
+
+	data, err := os.ReadFile(fallback)
+	if err != nil || string(data) != `{"pool":{"workers":8}}` {
+		t.Fatalf("fallback file = %s (err %v), want the v2 config", data, err)
+	}
+}
+
+func TestOnChange(t *testing.T) {
+	worker, srv := newFakeWorker(t, "v1", `{"database":{"host":"a","port":5432},"debug":false}`)
+
+	client := newTestClient(t, Options{URL: srv.URL})
+	var mu sync.Mutex
+	calls := map[string][2]any{}
+	for _, key := range []string{"database.host", "database.port", "/debug", "missing"} {
+		client.OnChange(key, func(old, new any) {
+			mu.Lock()
+			defer mu.Unlock()
+			calls[key] = [2]any{old, new}
+		})
+	}
+
+	err := client.Start(context.Background())
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	worker.publish("v2", `{"database":{"host":"b","port":5432},"debug":true}`)
+	waitFor(t, "v2", func() bool { return client.Snapshot().ETag == "v2" })
+	// Close waits for the refresh goroutine, so every callback has run.
+	client.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(calls) != 2 {
+		t.Fatalf("callbacks = %v, want database.host and /debug only", calls)
+	}
+	if got := calls["database.host"]; got != [2]any{"a", "b"} {
+		t.Fatalf("database.host change = %v, want a -> b", got)
+	}
+	if got := calls["/debug"]; got != [2]any{false, true} {
+		t.Fatalf("/debug change = %v, want false -> true", got)
+	}
+}
+
+func TestSnapshotValues(t *testing.T) {
+	snapshot, err := newSnapshot("v1", []byte(`{
+		"database": {"host": "db-1", "port": 5432, "ratio": 0.5, "tls": true},
+		"flags": {"beta": "true", "empty": null},
+		"servers": ["a", "b"]
+	}`))
+	if err != nil {
+		t.Fatalf("new snapshot: %v", err)
+	}
+
+	stringTests := []struct {
+		key, def, want string
+	}{
+		{"database.host", "x", "db-1"},
+		{"/database/host", "x", "db-1"},
+		{"database.port", "x", "5432"},
+		{"servers.1", "x", "b"},
+		{"flags.empty", "x", "x"},
+		{"database.user", "x", "x"},
+		{"database.host.name", "x", "x"},
+	}
+	for _, tt := range stringTests {
+		if got := snapshot.String(tt.key, tt.def); got != tt.want {
+			t.Errorf("String(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+
+	intTests := []struct {
+		key  string
+		def  int64
+		want int64
+	}{
+		{"database.port", 1, 5432},
+		{"database.host", 1, 1},
+		{"database.ratio", 1, 1},
+		{"database.user", 1, 1},
+	}
+	for _, tt := range intTests {
+		if got := snapshot.Int(tt.key, tt.def); got != tt.want {
+			t.Errorf("Int(%q) = %d, want %d", tt.key, got, tt.want)
+		}
+	}
+
+	boolTests := []struct {
+		key       string
+		def, want bool
+	}{
+		{"database.tls", false, true},
+		{"flags.beta", false, true},
+		{"database.port", true, true},
+		{"database.host", false, false},
+		{"flags.empty", true, true},
+	}
+	for _, tt := range boolTests {
+		if got := snapshot.Bool(tt.key, tt.def); got != tt.want {
+			t.Errorf("Bool(%q) = %t, want %t", tt.key, got, tt.want)
+		}
+	}
+
+	if got := snapshot.Float("database.ratio", 0); got != 0.5 {
+		t.Errorf("Float(database.ratio) = %v, want 0.5", got)
+	}
+	if got := snapshot.Float("database.host", 1.5); got != 1.5 {
+		t.Errorf("Float(database.host) = %v, want the default 1.5", got)
+	}
+}
+
+func TestSnapshotBind(t *testing.T) {
+	var cfg struct {
+		Database struct {
+			Host string `json:"host"`
+			Port int    `json:"port"`
+		} `json:"database"`
+	}
+
+	err := Snapshot{}.Bind(&cfg)
+	if !errors.Is(err, ErrNoConfig) {
+		t.Fatalf("bind empty snapshot: err = %v, want ErrNoConfig", err)
+	}
+
+	snapshot, err := newSnapshot("v1", []byte(`{"database":{"host":"db-1","port":5432}}`))
+	if err != nil {
+		t.Fatalf("new snapshot: %v", err)
+	}
+	err = snapshot.Bind(&cfg)
+	if err != nil {
+		t.Fatalf("bind: %v", err)
+	}
+	if cfg.Database.Host != "db-1" || cfg.Database.Port != 5432 {
+		t.Fatalf("bound %+v, want db-1:5432", cfg.Database)
+	}
+
+	var mismatch struct {
+		Database struct {
+			Port string `json:"port"`
+		} `json:"database"`
+	}
+	err = snapshot.Bind(&mismatch)
+	if err == nil {
+		t.Fatal("bind a number into a string succeeded, want an error")
+	}
+}
@@ -0,0 +1,84 @@
+package configclient_test
+
+import (
+	"context"
+	"distributed-configuration/pkg/configclient"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+func Example() {
+	client, err := configclient.New(configclient.Options{
+		URL:          "http://localhost:8181",
+		Secret:       os.Getenv("CLIENT_SECRET"),
+		FallbackFile: "/var/lib/app/config.json",
+		OnError:      func(err error) { log.Println("config refresh:", err) },
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = client.Start(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer client.Close()
+
+	var cfg struct {
+		Database struct {
+			Host string `json:"host"`
+			Port int    `json:"port"`
+		} `json:"database"`
+	}
+	err = client.Bind(&cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("connecting to %s:%d\n", cfg.Database.Host, cfg.Database.Port)
+}
+
+func ExampleClient_OnChange() {
+	client, err := configclient.New(configclient.Options{
+		URL:    "http://localhost:8181",
+		Secret: os.Getenv("CLIENT_SECRET"),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// register before Start so no change is missed.
+	client.OnChange("database.host", func(old, new any) {
+		log.Printf("database moved from %v to %v, reconnecting", old, new)
+	})
+
+	err = client.Start(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer client.Close()
+}
+
+func ExampleClient_Int() {
+	client, err := configclient.New(configclient.Options{
+		URL:          "http://localhost:8181",
+		Secret:       os.Getenv("CLIENT_SECRET"),
+		WatchTimeout: 30 * time.Second,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = client.Start(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer client.Close()
+
+	// dotted paths and JSON Pointers address the same values, the default
+	// is returned when the key is missing or has another type.
+	workers := client.Int("pool.workers", 4)
+	timeout := client.String("/http/timeout", "5s")
+	fmt.Println(workers, timeout)
+}
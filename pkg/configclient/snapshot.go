@@ -0,0 +1,83 @@
+package configclient
+
+import (
+	"distributed-configuration/pkg/utils"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Snapshot is one version of the configuration. ETag is empty when it was
+// read from the fallback file.
+type Snapshot struct {
+	ETag string
+	Data json.RawMessage
+	doc  any
+}
+
+func newSnapshot(etag string, data []byte) (Snapshot, error) {
+	doc, err := utils.DecodeJSON(data)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("configclient: invalid config: %w", err)
+	}
+
+	return Snapshot{ETag: etag, Data: data, doc: doc}, nil
+}
+
+// Bind decodes the configuration into v.
+func (s Snapshot) Bind(v any) error {
+	if s.Data == nil {
+		return ErrNoConfig
+	}
+	return json.Unmarshal(s.Data, v)
+}
+
+// Get returns the value at key, a dotted path (database.host) or a JSON
+// Pointer (/database/host). Objects are map[string]any, arrays []any and
+// numbers json.Number.
+func (s Snapshot) Get(key string) (any, bool) {
+	if s.Data == nil {
+		return nil, false
+	}
+
+	value, ok, err := utils.Lookup(s.doc, utils.KeyPointer(key))
+	if err != nil {
+		return nil, false
+	}
+	return value, ok
+}
+
+func (s Snapshot) String(key, def string) string {
+	v, ok := s.Get(key)
+	if !ok || v == nil {
+		return def
+	}
+	if str, ok := v.(string); ok {
+		return str
+	}
+	return fmt.Sprint(v)
+}
+
+func (s Snapshot) Int(key string, def int64) int64 {
+	i, err := strconv.ParseInt(s.String(key, ""), 10, 64)
+	if err != nil {
+		return def
+	}
+	return i
+}
+
+func (s Snapshot) Float(key string, def float64) float64 {
+	f, err := strconv.ParseFloat(s.String(key, ""), 64)
+	if err != nil {
+		return def
+	}
+	return f
+}
+
+func (s Snapshot) Bool(key string, def bool) bool {
+	b, err := strconv.ParseBool(s.String(key, ""))
+	if err != nil {
+		return def
+	}
+	return b
+}
@@ -1,17 +1,46 @@
 package model
 
 import (
+	"distributed-configuration/pkg/utils"
 	"encoding/json"
 	"sync"
+	"time"
 )
 
 type AgentState struct {
 	mu                  sync.RWMutex
-	AgentID             string          `json:"agent_id"`
-	ETag                string          `json:"etag"`
-	PollUrl             string          `json:"poll_url"`
-	PollIntervalSeconds int             `json:"poll_interval_seconds"`
-	Config              json.RawMessage `json:"config"`
+	AgentID             string              `json:"agent_id"`
+	ControllerUrl       string              `json:"controller_url"`
+	ETag                string              `json:"etag"`
+	PollUrl             string              `json:"poll_url"`
+	PollIntervalSeconds int                 `json:"poll_interval_seconds"`
+	Config              json.RawMessage     `json:"config"`
+	Deliveries          map[string]Delivery `json:"deliveries"`
+	Hooks               []HookResult        `json:"hooks,omitempty"`
+	RejectedETag        string              `json:"rejected_etag,omitempty"`
+	LastPoll            time.Time           `json:"last_poll"`
+	LastPollError       string              `json:"last_poll_error,omitempty"`
+}
+
+// Delivery is the last delivery outcome for one sink. Failed deliveries
+// are retried at NextRetry.
+type Delivery struct {
+	ETag      string    `json:"etag"`
+	LastError string    `json:"last_error,omitempty"`
+	Attempts  int       `json:"attempts,omitempty"`
+	NextRetry time.Time `json:"next_retry"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// HookResult is the outcome of one reload hook run.
+type HookResult struct {
+	Name     string    `json:"name"`
+	ETag     string    `json:"etag"`
+	Success  bool      `json:"success"`
+	ExitCode int       `json:"exit_code,omitempty"`
+	Output   string    `json:"output,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	RanAt    time.Time `json:"ran_at"`
 }
 
 func (s *AgentState) RegistraionData(agentID, pollUrl string, interval int) {
@@ -27,6 +56,13 @@ func (s *AgentState) UpdateConfig(etag string, config []byte) {
 	defer s.mu.Unlock()
 	s.ETag = etag
 	s.Config = config
+
+	// a new config restarts the retry schedule of every sink.
+	for name, delivery := range s.Deliveries {
+		delivery.Attempts = 0
+		delivery.NextRetry = time.Time{}
+		s.Deliveries[name] = delivery
+	}
 }
 
 func (s *AgentState) Get() (string, string, string) {
@@ -35,6 +71,152 @@ func (s *AgentState) Get() (string, string, string) {
 	return s.AgentID, s.ETag, s.PollUrl
 }
 
+func (s *AgentState) SetController(url string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ControllerUrl = url
+}
+
+func (s *AgentState) GetController() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ControllerUrl
+}
+
+// PendingSinks returns the sinks that have not received the current config
+// yet.
+func (s *AgentState) PendingSinks(sinks []string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	pending := []string{}
+	for _, name := range sinks {
+		if s.Deliveries[name].ETag != s.ETag {
+			pending = append(pending, name)
+		}
+	}
+	return pending
+}
+
+// DueSinks returns the pending sinks whose retry time has passed.
+func (s *AgentState) DueSinks(sinks []string, now time.Time) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	due := []string{}
+	for _, name := range sinks {
+		delivery := s.Deliveries[name]
+		if delivery.ETag != s.ETag && !delivery.NextRetry.After(now) {
+			due = append(due, name)
+		}
+	}
+	return due
+}
+
+// NextRetry returns the earliest retry time of the pending sinks, ok is
+// false when nothing is pending. A pending sink that was never tried is due
+// right away, its retry time is zero.
+func (s *AgentState) NextRetry(sinks []string) (next time.Time, ok bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, name := range sinks {
+		delivery := s.Deliveries[name]
+		if delivery.ETag == s.ETag {
+			continue
+		}
+		if !ok || delivery.NextRetry.Before(next) {
+			next = delivery.NextRetry
+			ok = true
+		}
+	}
+	return next, ok
+}
+
+func (s *AgentState) Delivery(name string) Delivery {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.Deliveries[name]
+}
+
+// RecordDelivery stores a push outcome, a failed push is retried at
+// nextRetry.
+func (s *AgentState) RecordDelivery(name, etag string, err error, nextRetry time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Deliveries == nil {
+		s.Deliveries = make(map[string]Delivery)
+	}
+
+	delivery := s.Deliveries[name]
+	delivery.UpdatedAt = time.Now()
+	if err != nil {
+		delivery.LastError = err.Error()
+		delivery.Attempts++
+		delivery.NextRetry = nextRetry
+	} else {
+		delivery.ETag = etag
+		delivery.LastError = ""
+		delivery.Attempts = 0
+		delivery.NextRetry = time.Time{}
+	}
+	s.Deliveries[name] = delivery
+}
+
+// RecordPoll stores when the controller was last polled and why it failed,
+// err is nil for a successful poll.
+func (s *AgentState) RecordPoll(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.LastPoll = time.Now()
+	s.LastPollError = ""
+	if err != nil {
+		s.LastPollError = err.Error()
+	}
+}
+
+// ResetRegistration forgets the agent id so the agent registers again.
+func (s *AgentState) ResetRegistration() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.AgentID = ""
+}
+
+func (s *AgentState) RecordHooks(results []HookResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Hooks = results
+}
+
+// Reject remembers a config version whose reload hooks failed so it is not
+// applied again.
+func (s *AgentState) Reject(etag string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.RejectedETag = etag
+}
+
+func (s *AgentState) IsRejected(etag string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return etag != "" && etag == s.RejectedETag
+}
+
+// PollETag is the version to long-poll against. While the rejected version
+// is newer than the applied one the poll waits for a version after it, so it
+// is not handed the rejected one again.
+func (s *AgentState) PollETag() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.RejectedETag != "" && utils.ParseVersion(s.RejectedETag) > utils.ParseVersion(s.ETag) {
+		return s.RejectedETag
+	}
+	return s.ETag
+}
+
 func (s *AgentState) GetInterval() int {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -50,15 +232,55 @@ func (s *AgentState) Snapshot() *AgentState {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	deliveries := make(map[string]Delivery, len(s.Deliveries))
+	for name, delivery := range s.Deliveries {
+		deliveries[name] = delivery
+	}
+
 	return &AgentState{
 		AgentID:             s.AgentID,
+		ControllerUrl:       s.ControllerUrl,
 		ETag:                s.ETag,
 		PollUrl:             s.PollUrl,
 		PollIntervalSeconds: s.PollIntervalSeconds,
 		Config:              s.Config,
+		Deliveries:          deliveries,
+		Hooks:               append([]HookResult(nil), s.Hooks...),
+		RejectedETag:        s.RejectedETag,
+		LastPoll:            s.LastPoll,
+		LastPollError:       s.LastPollError,
 	}
 }
 
+// Status is the agent state without the cached config, as served by the
+// local status API.
+func (s *AgentState) Status() AgentStatus {
+	snapshot := s.Snapshot()
+	return AgentStatus{
+		AgentID:       snapshot.AgentID,
+		ControllerUrl: snapshot.ControllerUrl,
+		ETag:          snapshot.ETag,
+		PollUrl:       snapshot.PollUrl,
+		LastPoll:      snapshot.LastPoll,
+		LastPollError: snapshot.LastPollError,
+		Deliveries:    snapshot.Deliveries,
+		Hooks:         snapshot.Hooks,
+		RejectedETag:  snapshot.RejectedETag,
+	}
+}
+
+type AgentStatus struct {
+	AgentID       string              `json:"agent_id"`
+	ControllerUrl string              `json:"controller_url"`
+	ETag          string              `json:"etag"`
+	PollUrl       string              `json:"poll_url"`
+	LastPoll      time.Time           `json:"last_poll"`
+	LastPollError string              `json:"last_poll_error,omitempty"`
+	Deliveries    map[string]Delivery `json:"deliveries"`
+	Hooks         []HookResult        `json:"hooks,omitempty"`
+	RejectedETag  string              `json:"rejected_etag,omitempty"`
+}
+
 type ConfigResponse struct {
 	ETag string
 	Data json.RawMessage `json:"data"`
@@ -1,7 +1,8 @@
 package model
 
 import (
-	"encoding/json"
+	"crypto/sha256"
+	"encoding/hex"
 	"time"
 )
 
@@ -10,6 +11,7 @@ type Agent struct {
 	Name                string    `json:"name"`
 	Host                string    `json:"host"`
 	PollIntervalSeconds int       `json:"poll_interval_seconds"`
+	ConfigVersion       int       `json:"config_version"`
 	CreatedAt           time.Time `json:"created_at"`
 	LastSeen            time.Time `json:"last_seen"`
 }
@@ -21,6 +23,9 @@ func (a *Agent) TableName() string {
 type AgentRequest struct {
 	Name string `json:"name"`
 	Host string `json:"host"`
+	// ETag is the config the agent already applied, sent when it registers
+	// again after the controller forgot it.
+	ETag string `json:"etag,omitempty"`
 }
 
 type AgentResponse struct {
@@ -30,12 +35,80 @@ type AgentResponse struct {
 }
 
 type Configuration struct {
-	ID        uint            `gorm:"primaryKey;autoIncrement:true;column:id;unique" json:"-"`
-	Version   int             `gorm:"index;column:version" json:"-"`
-	Data      json.RawMessage `gorm:"column:data" json:"data" swaggertype:"object"`
-	CreatedAt time.Time       `gorm:"column:created_at" json:"-"`
+	ID        uint      `gorm:"primaryKey;autoIncrement:true;column:id;unique" json:"-"`
+	Version   int       `gorm:"uniqueIndex:idx_configurations_version;column:version" json:"-"`
+	Data      JSON      `gorm:"column:data" json:"data" swaggertype:"object"`
+	Pinned    bool      `gorm:"column:pinned" json:"-"`
+	CreatedAt time.Time `gorm:"column:created_at" json:"-"`
 }
 
 func (a *Configuration) TableName() string {
 	return "configurations"
 }
+
+func (a *Configuration) Hash() string {
+	sum := sha256.Sum256(a.Data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Info describes the version for the admin API, with its data when it was
+// loaded.
+func (a *Configuration) Info() ConfigVersion {
+	info := ConfigVersion{
+		Version:   a.Version,
+		Pinned:    a.Pinned,
+		CreatedAt: a.CreatedAt,
+	}
+	if len(a.Data) > 0 {
+		info.Hash = a.Hash()
+		info.Data = a.Data
+	}
+	return info
+}
+
+// ConfigVersion is one stored configuration version as reported by the admin
+// API, history listings leave out hash and data.
+type ConfigVersion struct {
+	Version   int       `json:"version"`
+	Hash      string    `json:"hash,omitempty"`
+	Pinned    bool      `json:"pinned"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      JSON      `json:"data,omitempty" swaggertype:"object"`
+}
+
+type ConfigUpdate struct {
+	Namespace string `json:"namespace"`
+	Version   int    `json:"version"`
+	Hash      string `json:"hash,omitempty"`
+	// Invalidate asks every replica to drop its cached configuration and
+	// wakes all long-polls, sent after a restore rewrote stored versions.
+	Invalidate bool `json:"invalidate,omitempty"`
+}
+
+// Backup is the portable archive of the controller state.
+type Backup struct {
+	FormatVersion  int                   `json:"format_version"`
+	SchemaVersion  int                   `json:"schema_version"`
+	CreatedAt      time.Time             `json:"created_at"`
+	Configurations []BackupConfiguration `json:"configurations"`
+	Agents         []Agent               `json:"agents"`
+}
+
+type BackupConfiguration struct {
+	Version   int       `json:"version"`
+	Data      JSON      `json:"data"`
+	Pinned    bool      `json:"pinned"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type RestoreResult struct {
+	Inserted int `json:"inserted"`
+	Updated  int `json:"updated"`
+	Skipped  int `json:"skipped"`
+}
+
+type ReplicaStatus struct {
+	ReplicaID string `json:"replica_id"`
+	Role      string `json:"role"`
+	LeaderID  string `json:"leader_id"`
+}
@@ -3,22 +3,152 @@ package model
 import (
 	"encoding/json"
 	"sync"
+	"time"
 )
 
+// ConfigRecord is one configuration version received by a worker.
+// Revision counts the versions the worker stored, ETag is the version the
+// agent pushed and Hash is the sha256 of Config.
+type ConfigRecord struct {
+	Revision  int             `json:"revision"`
+	ETag      string          `json:"etag,omitempty"`
+	Hash      string          `json:"hash"`
+	Config    json.RawMessage `json:"config,omitempty" swaggertype:"object"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// Tag is the etag served to clients, the pushed version or, for pushes
+// without one, a prefix of the content hash.
+func (r ConfigRecord) Tag() string {
+	if r.ETag != "" {
+		return r.ETag
+	}
+	if len(r.Hash) > 16 {
+		return r.Hash[:16]
+	}
+	return r.Hash
+}
+
+// DataConfig is the configuration a worker serves together with the last
+// versions it received, newest first. It is persisted as a whole so the
+// worker keeps serving after a restart.
 type DataConfig struct {
-	mu     sync.RWMutex
-	Config json.RawMessage `json:"config"`
+	mu      sync.RWMutex
+	Current *ConfigRecord  `json:"current"`
+	History []ConfigRecord `json:"history"`
 }
 
-func (d *DataConfig) UpdateData(config json.RawMessage) {
+// UpdateData stores config as a new revision and keeps at most keep
+// versions in the history. It returns false when config is already served.
+func (d *DataConfig) UpdateData(config json.RawMessage, etag, hash string, keep int) (ConfigRecord, bool) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	d.Config = config
+
+	if d.Current != nil && d.Current.Hash == hash && d.Current.ETag == etag {
+		return *d.Current, false
+	}
+
+	record := ConfigRecord{
+		Revision:  1,
+		ETag:      etag,
+		Hash:      hash,
+		Config:    config,
+		UpdatedAt: time.Now(),
+	}
+	if d.Current != nil {
+		record.Revision = d.Current.Revision + 1
+	}
+
+	d.Current = &record
+	d.History = append([]ConfigRecord{record}, d.History...)
+	if keep > 0 && len(d.History) > keep {
+		d.History = d.History[:keep]
+	}
+
+	return record, true
+}
+
+// GetConfig returns the served config and the etag clients cache it by.
+func (d *DataConfig) GetConfig() (json.RawMessage, string) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.Current == nil {
+		return nil, ""
+	}
+	return d.Current.Config, d.Current.Tag()
+}
+
+// Latest returns the record being served.
+func (d *DataConfig) Latest() (ConfigRecord, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.Current == nil {
+		return ConfigRecord{}, false
+	}
+	return *d.Current, true
 }
 
-func (d *DataConfig) GetConfig() json.RawMessage {
+// Version returns the record being served without its config.
+func (d *DataConfig) Version() (ConfigRecord, bool) {
 	d.mu.RLock()
 	defer d.mu.RUnlock()
 
-	return d.Config
+	if d.Current == nil {
+		return ConfigRecord{}, false
+	}
+
+	record := *d.Current
+	record.Config = nil
+	return record, true
+}
+
+// Revision returns the stored record of revision.
+func (d *DataConfig) Revision(revision int) (ConfigRecord, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	for _, record := range d.History {
+		if record.Revision == revision {
+			return record, true
+		}
+	}
+	return ConfigRecord{}, false
+}
+
+// Versions lists the history without the config bodies.
+func (d *DataConfig) Versions() []ConfigRecord {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	res := make([]ConfigRecord, len(d.History))
+	for i, record := range d.History {
+		record.Config = nil
+		res[i] = record
+	}
+	return res
+}
+
+func (d *DataConfig) Snapshot() *DataConfig {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	snapshot := &DataConfig{
+		History: append([]ConfigRecord(nil), d.History...),
+	}
+	if d.Current != nil {
+		current := *d.Current
+		snapshot.Current = &current
+	}
+	return snapshot
+}
+
+// Restore replaces the data with a persisted snapshot.
+func (d *DataConfig) Restore(data *DataConfig) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.Current = data.Current
+	d.History = data.History
 }
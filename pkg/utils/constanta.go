@@ -7,3 +7,10 @@ const (
 	RoleAgent  Role = "agent"
 	RoleClient Role = "client"
 )
+
+const DefaultNamespace = "default"
+
+const (
+	RoleLeader   = "leader"
+	RoleFollower = "follower"
+)
@@ -12,11 +12,19 @@ var (
 	ErrInvalidInput = errors.New("invalid input")
 	ErrInternal     = errors.New("internal error")
 	ErrNotModified  = errors.New("data not modified")
+
+	// ErrPreconditionFailed rejects a write based on a version that is no
+	// longer the latest, see the If-Match header.
+	ErrPreconditionFailed = errors.New("version precondition failed")
+
+	// ErrUnknownAgent tells an agent the controller has no record of it,
+	// e.g. after the database was reset, so it has to register again.
+	ErrUnknownAgent = errors.New("unknown agent")
 )
 
 func MapError(err error) (int, string) {
 	switch err {
-	case ErrNotFound:
+	case ErrNotFound, ErrUnknownAgent:
 		return http.StatusNotFound, err.Error()
 	case ErrInvalidInput:
 		return http.StatusBadRequest, err.Error()
@@ -26,6 +34,8 @@ func MapError(err error) (int, string) {
 		return http.StatusConflict, err.Error()
 	case ErrNotModified:
 		return http.StatusNotModified, err.Error()
+	case ErrPreconditionFailed:
+		return http.StatusPreconditionFailed, err.Error()
 	default:
 		return http.StatusInternalServerError, "internal server error"
 	}
@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "backup.json")
+
+	err := WriteFileAtomic(path, []byte("old"), 0644)
+	if err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	failed := errors.New("export failed")
+	err = WriteAtomic(path, 0644, func(w io.Writer) error {
+		w.Write([]byte("partial"))
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("err = %v, want the write error", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "old" {
+		t.Fatalf("file = %q (err %v), want the old content", data, err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("dir holds %d entries (err %v), want no temp file left", len(entries), err)
+	}
+
+	err = WriteAtomic(path, 0600, func(w io.Writer) error {
+		_, err := w.Write([]byte("new"))
+		return err
+	})
+	if err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("mode = %v (err %v), want 0600", info.Mode().Perm(), err)
+	}
+}
@@ -0,0 +1,514 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"mime"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"go.yaml.in/yaml/v3"
+)
+
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+	FormatTOML = "toml"
+	FormatEnv  = "env"
+)
+
+// DecodeJSON decodes data keeping numbers as json.Number so no precision is
+// lost before re-encoding.
+func DecodeJSON(data []byte) (any, error) {
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	err := dec.Decode(&v)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Encode converts a JSON document into the given format.
+func Encode(format string, data json.RawMessage) ([]byte, error) {
+	switch format {
+	case FormatJSON, "":
+		var buf bytes.Buffer
+		err := json.Indent(&buf, data, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteByte('\n')
+		return buf.Bytes(), nil
+	case FormatYAML:
+		v, err := DecodeJSON(data)
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		err = enc.Encode(yamlValue(v))
+		if err == nil {
+			err = enc.Close()
+		}
+		if err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case FormatTOML:
+		v, err := DecodeJSON(data)
+		if err != nil {
+			return nil, err
+		}
+		native, err := nativeValue(v)
+		if err != nil {
+			return nil, err
+		}
+		table, ok := native.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("toml requires an object at the top level")
+		}
+		var buf bytes.Buffer
+		err = toml.NewEncoder(&buf).Encode(table)
+		if err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case FormatEnv:
+		v, err := DecodeJSON(data)
+		if err != nil {
+			return nil, err
+		}
+		return encodeEnv(v), nil
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+}
+
+// yamlValue emits numbers as untouched YAML scalars.
+func yamlValue(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		res := make(map[string]any, len(t))
+		for k, val := range t {
+			res[k] = yamlValue(val)
+		}
+		return res
+	case []any:
+		res := make([]any, len(t))
+		for i, val := range t {
+			res[i] = yamlValue(val)
+		}
+		return res
+	case json.Number:
+		// literals yaml resolves to a number stay untagged, e.g. integers
+		// beyond 64 bits resolve to !!float. Others, like 1e400, are tagged
+		// so they are not read back as strings.
+		node := &yaml.Node{Kind: yaml.ScalarNode, Value: t.String()}
+		if tag := node.ShortTag(); tag != "!!int" && tag != "!!float" {
+			node.Tag = "!!float"
+		}
+		return node
+	default:
+		return v
+	}
+}
+
+// nativeValue turns json.Number into int64 or float64 for encoders that
+// need Go number types. Integers beyond int64 are rejected instead of being
+// rounded to a float, and so is null, which TOML cannot express.
+func nativeValue(v any) (any, error) {
+	switch t := v.(type) {
+	case map[string]any:
+		res := make(map[string]any, len(t))
+		for k, val := range t {
+			native, err := nativeValue(val)
+			if err != nil {
+				return nil, err
+			}
+			res[k] = native
+		}
+		return res, nil
+	case []any:
+		res := make([]any, len(t))
+		for i, val := range t {
+			native, err := nativeValue(val)
+			if err != nil {
+				return nil, err
+			}
+			res[i] = native
+		}
+		return res, nil
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i, nil
+		}
+		if !strings.ContainsAny(t.String(), ".eE") {
+			return nil, fmt.Errorf("integer %s does not fit 64 bits", t)
+		}
+		f, _ := t.Float64()
+		return f, nil
+	case nil:
+		return nil, fmt.Errorf("null has no toml representation")
+	default:
+		return v, nil
+	}
+}
+
+var envKeyRegex = regexp.MustCompile(`[^A-Z0-9_]+`)
+
+// encodeEnv flattens the document into KEY=value lines, nested keys are
+// joined with an underscore, e.g. {"db":{"host":"x"}} becomes DB_HOST="x".
+func encodeEnv(v any) []byte {
+	vars := map[string]string{}
+	flattenEnv("", v, vars)
+
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	for _, k := range keys {
+		fmt.Fprintf(&buf, "%s=%s\n", k, vars[k])
+	}
+	return buf.Bytes()
+}
+
+func flattenEnv(prefix string, v any, vars map[string]string) {
+	join := func(key string) string {
+		key = envKeyRegex.ReplaceAllString(strings.ToUpper(key), "_")
+		if prefix == "" {
+			return key
+		}
+		return prefix + "_" + key
+	}
+
+	switch t := v.(type) {
+	case map[string]any:
+		for k, val := range t {
+			flattenEnv(join(k), val, vars)
+		}
+	case []any:
+		for i, val := range t {
+			flattenEnv(join(strconv.Itoa(i)), val, vars)
+		}
+	case string:
+		vars[prefix] = strconv.Quote(t)
+	case nil:
+		vars[prefix] = ""
+	default:
+		vars[prefix] = fmt.Sprint(t)
+	}
+}
+
+var mediaTypes = map[string]string{
+	"application/json":   FormatJSON,
+	"application/yaml":   FormatYAML,
+	"application/x-yaml": FormatYAML,
+	"text/yaml":          FormatYAML,
+	"text/x-yaml":        FormatYAML,
+	"application/toml":   FormatTOML,
+}
+
+// FormatFromContentType returns the format named by a Content-Type header,
+// JSON for anything else so clients posting JSON without the header keep
+// working.
+func FormatFromContentType(header string) string {
+	mediaType, _, err := mime.ParseMediaType(header)
+	if err != nil {
+		return FormatJSON
+	}
+
+	format, ok := mediaTypes[mediaType]
+	if !ok {
+		return FormatJSON
+	}
+	return format
+}
+
+// NegotiateFormat picks the format an Accept header prefers, by q value
+// and then by order, JSON when it accepts none of the known formats.
+func NegotiateFormat(accept string) string {
+	best, bestQ := FormatJSON, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		format, ok := mediaTypes[mediaType]
+		if !ok {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			q, err = strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+		}
+		if q > bestQ {
+			best, bestQ = format, q
+		}
+	}
+
+	return best
+}
+
+func ContentType(format string) string {
+	switch format {
+	case FormatYAML:
+		return "application/yaml"
+	case FormatTOML:
+		return "application/toml"
+	default:
+		return "application/json"
+	}
+}
+
+// Decode converts a document in the given format into JSON. YAML numbers
+// keep their literal digits, TOML numbers are 64 bit by its spec.
+func Decode(format string, data []byte) (json.RawMessage, error) {
+	switch format {
+	case FormatJSON, "":
+		// DecodeJSON stops after the first value, trailing data would be
+		// stored along with it.
+		if !json.Valid(data) {
+			_, err := DecodeJSON(data)
+			if err == nil {
+				err = fmt.Errorf("invalid json: unexpected data after the document")
+			}
+			return nil, err
+		}
+		return data, nil
+	case FormatYAML:
+		var doc yaml.Node
+		err := yaml.Unmarshal(data, &doc)
+		if err != nil {
+			return nil, err
+		}
+		if len(doc.Content) == 0 {
+			return nil, fmt.Errorf("empty yaml document")
+		}
+
+		var buf bytes.Buffer
+		err = yamlToJSON(&buf, &doc, 0)
+		if err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case FormatTOML:
+		var v map[string]any
+		_, err := toml.Decode(string(data), &v)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(v)
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+}
+
+const (
+	maxYAMLDepth = 1000
+	maxYAMLSize  = 64 << 20
+)
+
+// yamlToJSON writes the node as JSON in document order. Aliases are
+// expanded, so depth and size are bounded against recursive anchors and
+// alias bombs.
+func yamlToJSON(buf *bytes.Buffer, n *yaml.Node, depth int) error {
+	if depth > maxYAMLDepth {
+		return fmt.Errorf("yaml document nested too deep")
+	}
+	if buf.Len() > maxYAMLSize {
+		return fmt.Errorf("yaml document too large")
+	}
+
+	switch n.Kind {
+	case yaml.DocumentNode:
+		return yamlToJSON(buf, n.Content[0], depth+1)
+	case yaml.AliasNode:
+		return yamlToJSON(buf, n.Alias, depth+1)
+	case yaml.SequenceNode:
+		buf.WriteByte('[')
+		for i, item := range n.Content {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			err := yamlToJSON(buf, item, depth+1)
+			if err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+		return nil
+	case yaml.MappingNode:
+		members, err := yamlMembers(n)
+		if err != nil {
+			return err
+		}
+
+		buf.WriteByte('{')
+		for i, m := range members {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			key, _ := json.Marshal(m.key)
+			buf.Write(key)
+			buf.WriteByte(':')
+			err = yamlToJSON(buf, m.value, depth+1)
+			if err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+		return nil
+	case yaml.ScalarNode:
+		return yamlScalar(buf, n)
+	default:
+		return fmt.Errorf("line %d: unsupported yaml node", n.Line)
+	}
+}
+
+type yamlMember struct {
+	key   string
+	value *yaml.Node
+}
+
+// yamlMembers lists the keys of a mapping, later keys replace earlier ones
+// and keys from merge keys (<<) only fill in what is not set explicitly.
+func yamlMembers(n *yaml.Node) ([]yamlMember, error) {
+	var (
+		members []yamlMember
+		merges  []*yaml.Node
+	)
+	index := map[string]int{}
+	set := func(key string, value *yaml.Node, replace bool) {
+		if i, ok := index[key]; ok {
+			if replace {
+				members[i].value = value
+			}
+			return
+		}
+		index[key] = len(members)
+		members = append(members, yamlMember{key: key, value: value})
+	}
+
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, value := resolveAlias(n.Content[i]), n.Content[i+1]
+		if key.Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf("line %d: yaml keys must be scalars", key.Line)
+		}
+		if key.ShortTag() == "!!merge" {
+			merges = append(merges, value)
+			continue
+		}
+		set(key.Value, value, true)
+	}
+
+	for _, merge := range merges {
+		merge = resolveAlias(merge)
+		sources := []*yaml.Node{merge}
+		if merge.Kind == yaml.SequenceNode {
+			sources = merge.Content
+		}
+
+		for _, src := range sources {
+			src = resolveAlias(src)
+			if src.Kind != yaml.MappingNode {
+				return nil, fmt.Errorf("line %d: merge value must be a mapping", src.Line)
+			}
+			merged, err := yamlMembers(src)
+			if err != nil {
+				return nil, err
+			}
+			for _, m := range merged {
+				set(m.key, m.value, false)
+			}
+		}
+	}
+
+	return members, nil
+}
+
+func resolveAlias(n *yaml.Node) *yaml.Node {
+	for n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	return n
+}
+
+// yamlScalar writes a scalar as JSON, numbers as their literal digits.
+func yamlScalar(buf *bytes.Buffer, n *yaml.Node) error {
+	switch n.ShortTag() {
+	case "!!null":
+		buf.WriteString("null")
+	case "!!bool":
+		var b bool
+		err := n.Decode(&b)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(strconv.FormatBool(b))
+	case "!!int":
+		var i int64
+		if err := n.Decode(&i); err == nil {
+			buf.WriteString(strconv.FormatInt(i, 10))
+			return nil
+		}
+		value, ok := new(big.Int).SetString(n.Value, 0)
+		if !ok {
+			return fmt.Errorf("line %d: invalid integer %q", n.Line, n.Value)
+		}
+		buf.WriteString(value.String())
+	case "!!float":
+		num, err := yamlFloat(n)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(num)
+	default:
+		str, _ := json.Marshal(n.Value)
+		buf.Write(str)
+	}
+
+	return nil
+}
+
+// yamlFloat keeps the literal when it is already a JSON number, otherwise
+// spellings like 1_000.5 or .5 are normalized.
+func yamlFloat(n *yaml.Node) (string, error) {
+	lit := strings.TrimPrefix(strings.ReplaceAll(n.Value, "_", ""), "+")
+	if strings.HasPrefix(lit, ".") {
+		lit = "0" + lit
+	} else if strings.HasPrefix(lit, "-.") {
+		lit = "-0" + lit[1:]
+	}
+
+	var num json.Number
+	if json.Unmarshal([]byte(lit), &num) == nil {
+		return num.String(), nil
+	}
+
+	var f float64
+	err := n.Decode(&f)
+	if err != nil {
+		return "", err
+	}
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return "", fmt.Errorf("line %d: %s has no json representation", n.Line, n.Value)
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64), nil
+}
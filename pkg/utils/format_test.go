@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		input  string
+		want   string
+		err    string
+	}{
+		{"json", FormatJSON, `{"a":1}`, `{"a":1}`, ""},
+		{"json trailing newline", FormatJSON, "{\"a\":1}\n", "{\"a\":1}\n", ""},
+		{"json trailing data", FormatJSON, `{"a":1} junk`, "", "invalid"},
+		{"json second document", FormatJSON, `{"a":1}{"b":2}`, "", "after the document"},
+		{"json invalid", FormatJSON, `{"a":`, "", "EOF"},
+
+		{"yaml", FormatYAML, "a: 1\nb: [x, true, null]\n", `{"a":1,"b":["x",true,null]}`, ""},
+		{"yaml alias", FormatYAML, "base: &b {host: db}\ncopy: *b\n", `{"base":{"host":"db"},"copy":{"host":"db"}}`, ""},
+		{"yaml scalar alias", FormatYAML, "a: &p 5432\nb: *p\n", `{"a":5432,"b":5432}`, ""},
+		{"yaml merge key", FormatYAML, "base: &b {host: db, port: 1}\nprod:\n  <<: *b\n  port: 2\n", `{"base":{"host":"db","port":1},"prod":{"port":2,"host":"db"}}`, ""},
+		{"yaml merge list", FormatYAML, "a: &a {x: 1}\nb: &b {x: 2, y: 2}\nc: {<<: [*a, *b]}\n", `{"a":{"x":1},"b":{"x":2,"y":2},"c":{"x":1,"y":2}}`, ""},
+		{"yaml merge scalar", FormatYAML, "a: &a 1\nb: {<<: *a}\n", "", "merge value must be a mapping"},
+		{"yaml big integer", FormatYAML, "n: 100000000000000000000\n", `{"n":100000000000000000000}`, ""},
+		{"yaml hex integer", FormatYAML, "n: 0x10\n", `{"n":16}`, ""},
+		{"yaml float spellings", FormatYAML, "a: 1_000.5\nb: .5\nc: -.5\nd: 1.50\n", `{"a":1000.5,"b":0.5,"c":-0.5,"d":1.50}`, ""},
+		{"yaml inf", FormatYAML, "n: .inf\n", "", "no json representation"},
+		{"yaml nan", FormatYAML, "n: .nan\n", "", "no json representation"},
+		{"yaml quoted number", FormatYAML, "n: \"1\"\n", `{"n":"1"}`, ""},
+		{"yaml complex key", FormatYAML, "? [a]\n: 1\n", "", "keys must be scalars"},
+		{"yaml empty", FormatYAML, "", "", "empty yaml document"},
+
+		{"toml", FormatTOML, "a = 1\n[db]\nhost = \"x\"\n", `{"a":1,"db":{"host":"x"}}`, ""},
+		{"toml invalid", FormatTOML, "a = \n", "", "expected value"},
+		{"unknown format", "xml", "<a/>", "", "unsupported format"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Decode(tt.format, []byte(tt.input))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		input  string
+		want   string
+		err    string
+	}{
+		{"json", FormatJSON, `{"a":[1,2]}`, "{\n  \"a\": [\n    1,\n    2\n  ]\n}\n", ""},
+		{"yaml", FormatYAML, `{"b":{"c":"x"},"a":1.5}`, "a: 1.5\nb:\n  c: x\n", ""},
+		{"yaml big integer", FormatYAML, `{"d":100000000000000000000}`, "d: 100000000000000000000\n", ""},
+		{"yaml number beyond float", FormatYAML, `{"d":1e400}`, "d: !!float 1e400\n", ""},
+		{"yaml numeric string", FormatYAML, `{"d":"1"}`, "d: \"1\"\n", ""},
+		{"toml", FormatTOML, `{"a":1,"db":{"host":"x"}}`, "a = 1\n\n[db]\n  host = \"x\"\n", ""},
+		{"toml big integer", FormatTOML, `{"n":100000000000000000000}`, "", "does not fit 64 bits"},
+		{"toml null", FormatTOML, `{"n":null}`, "", "null has no toml representation"},
+		{"toml nested null", FormatTOML, `{"a":[{"n":null}]}`, "", "null has no toml representation"},
+		{"toml list", FormatTOML, `[1]`, "", "object at the top level"},
+		{"env", FormatEnv, `{"db":{"host":"x","port":1},"list":["a"],"n":null}`, "DB_HOST=\"x\"\nDB_PORT=1\nLIST_0=\"a\"\nN=\n", ""},
+		{"unknown format", "xml", `{}`, "", "unsupported format"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Encode(tt.format, []byte(tt.input))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("encode: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		formats []string
+		input   string
+	}{
+		{"yaml", []string{FormatYAML}, `{"a":{"b":[1,"two",true,null,{"c":1.50}]},"big":100000000000000000000,"neg":-0.25,"exp":1e400,"s":"1"}`},
+		{"yaml strings", []string{FormatYAML}, `{"yes":"yes","null":"null","multi":"a\nb","empty":"","colon":"a: b"}`},
+		{"toml", []string{FormatTOML}, `{"a":1,"f":1.5,"s":"x","b":false,"list":[1,2],"db":{"host":"x","tables":[{"n":1},{"n":2}]}}`},
+		{"yaml then toml", []string{FormatYAML, FormatTOML}, `{"a":1,"db":{"host":"x","ports":[1,2]}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := []byte(tt.input)
+			for _, format := range tt.formats {
+				encoded, err := Encode(format, data)
+				if err != nil {
+					t.Fatalf("encode %s: %v", format, err)
+				}
+				data, err = Decode(format, encoded)
+				if err != nil {
+					t.Fatalf("decode %s: %v\n%s", format, err, encoded)
+				}
+			}
+
+			want, _ := DecodeJSON([]byte(tt.input))
+			got, err := DecodeJSON(data)
+			if err != nil {
+				t.Fatalf("decode result: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("round trip gave %s, want %s", data, tt.input)
+			}
+		})
+	}
+}
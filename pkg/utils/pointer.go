@@ -0,0 +1,188 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ParsePointer splits an RFC 6901 JSON Pointer such as /database/host into
+// its unescaped reference tokens. The empty pointer addresses the whole
+// document and yields no tokens.
+func ParsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid json pointer %q: must start with /", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+	return tokens, nil
+}
+
+// KeyPointer turns a dotted path such as database.host into a JSON Pointer,
+// keys starting with / are pointers already and pass as is.
+func KeyPointer(key string) string {
+	if key == "" || strings.HasPrefix(key, "/") {
+		return key
+	}
+
+	escaper := strings.NewReplacer("~", "~0", "/", "~1")
+	tokens := strings.Split(key, ".")
+	for i, token := range tokens {
+		tokens[i] = escaper.Replace(token)
+	}
+	return "/" + strings.Join(tokens, "/")
+}
+
+// selected marks a value that was picked as a whole, so a later pointer
+// into it does not narrow it down again.
+type selected struct {
+	value any
+}
+
+// selectedArray collects picked array elements by their original index.
+type selectedArray map[int]any
+
+// SelectJSON narrows data to the values addressed by the JSON Pointers while
+// keeping their place in the document, e.g. selecting /db/host from
+// {"db":{"host":"x","port":5432},"cache":{}} gives {"db":{"host":"x"}}.
+// Picked array elements keep their original order. Pointers that address
+// nothing are left out, so the result is an empty object when none match.
+func SelectJSON(data json.RawMessage, pointers []string) (json.RawMessage, error) {
+	doc, err := DecodeJSON(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var root any = map[string]any{}
+	for _, pointer := range pointers {
+		tokens, err := ParsePointer(pointer)
+		if err != nil {
+			return nil, err
+		}
+
+		value, ok := lookup(doc, tokens)
+		if !ok {
+			continue
+		}
+		if len(tokens) == 0 {
+			root = selected{value}
+			continue
+		}
+		root = insert(root, doc, tokens, value)
+	}
+
+	return json.Marshal(finalize(root))
+}
+
+// Lookup returns the value addressed by pointer in a document decoded with
+// DecodeJSON.
+func Lookup(doc any, pointer string) (any, bool, error) {
+	tokens, err := ParsePointer(pointer)
+	if err != nil {
+		return nil, false, err
+	}
+
+	value, ok := lookup(doc, tokens)
+	return value, ok, nil
+}
+
+func lookup(doc any, tokens []string) (any, bool) {
+	current := doc
+	for _, token := range tokens {
+		switch t := current.(type) {
+		case map[string]any:
+			v, ok := t[token]
+			if !ok {
+				return nil, false
+			}
+			current = v
+		case []any:
+			idx, ok := arrayIndex(token, len(t))
+			if !ok {
+				return nil, false
+			}
+			current = t[idx]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// insert places value at tokens inside node, mirroring the containers of
+// the source document along the way.
+func insert(node, source any, tokens []string, value any) any {
+	if _, ok := node.(selected); ok {
+		return node
+	}
+	if len(tokens) == 0 {
+		return selected{value}
+	}
+
+	token, rest := tokens[0], tokens[1:]
+	switch src := source.(type) {
+	case map[string]any:
+		obj, ok := node.(map[string]any)
+		if !ok {
+			obj = map[string]any{}
+		}
+		obj[token] = insert(obj[token], src[token], rest, value)
+		return obj
+	case []any:
+		arr, ok := node.(selectedArray)
+		if !ok {
+			arr = selectedArray{}
+		}
+		idx, _ := arrayIndex(token, len(src))
+		arr[idx] = insert(arr[idx], src[idx], rest, value)
+		return arr
+	default:
+		return node
+	}
+}
+
+func finalize(node any) any {
+	switch t := node.(type) {
+	case selected:
+		return t.value
+	case map[string]any:
+		for k, v := range t {
+			t[k] = finalize(v)
+		}
+		return t
+	case selectedArray:
+		indexes := make([]int, 0, len(t))
+		for idx := range t {
+			indexes = append(indexes, idx)
+		}
+		sort.Ints(indexes)
+
+		res := make([]any, 0, len(indexes))
+		for _, idx := range indexes {
+			res = append(res, finalize(t[idx]))
+		}
+		return res
+	default:
+		return t
+	}
+}
+
+func arrayIndex(token string, length int) (int, bool) {
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, false
+	}
+
+	idx, err := strconv.Atoi(token)
+	if err != nil || idx < 0 || idx >= length {
+		return 0, false
+	}
+	return idx, true
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"bytes"
 	"encoding/json"
 	"net/http"
 )
@@ -10,3 +11,31 @@ func WriteJSON(w http.ResponseWriter, status int, v interface{}) {
 	w.WriteHeader(status)
 	_ = json.NewEncoder(w).Encode(v)
 }
+
+// WriteDocument writes the JSON document data in the format the request
+// accepts, 406 when the document cannot be expressed in it.
+func WriteDocument(w http.ResponseWriter, r *http.Request, status int, data json.RawMessage) {
+	format := NegotiateFormat(r.Header.Get("Accept"))
+	w.Header().Add("Vary", "Accept")
+
+	var (
+		out []byte
+		err error
+	)
+	if format == FormatJSON {
+		var buf bytes.Buffer
+		err = json.Compact(&buf, data)
+		buf.WriteByte('\n')
+		out = buf.Bytes()
+	} else {
+		out, err = Encode(format, data)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotAcceptable)
+		return
+	}
+
+	w.Header().Set("Content-Type", ContentType(format))
+	w.WriteHeader(status)
+	w.Write(out)
+}
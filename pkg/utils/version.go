@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var versionRegex = regexp.MustCompile(`\d+`)
+
+func FormatVersion(version int) string {
+	return fmt.Sprintf("v%d", version)
+}
+
+func ParseVersion(etag string) int {
+	version, _ := strconv.Atoi(versionRegex.FindString(etag))
+	return version
+}
+
+// FormatSubtreeVersion tags a selected part of a configuration with the
+// version it was taken from and a hash of its content, e.g. v12-3f2a9c0d1e4b5a69.
+// Two versions that leave the subtree untouched share the same hash.
+func FormatSubtreeVersion(version int, data []byte) string {
+	sum := sha256.Sum256(data)
+	return fmt.Sprintf("v%d-%s", version, hex.EncodeToString(sum[:8]))
+}
+
+// SubtreeHash returns the content hash of an ETag built by
+// FormatSubtreeVersion, or an empty string for a plain version.
+func SubtreeHash(etag string) string {
+	_, hash, _ := strings.Cut(strings.Trim(etag, `"`), "-")
+	return hash
+}
+
+// MatchETag reports whether an If-None-Match or If-Match header value
+// matches etag. The header may list several, optionally quoted or weak,
+// etags or be "*".
+func MatchETag(header, etag string) bool {
+	if header == "" || etag == "" {
+		return false
+	}
+
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		candidate = strings.Trim(strings.TrimPrefix(candidate, "W/"), `"`)
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}